@@ -9,99 +9,471 @@ import (
 	"github.com/shogo82148/goa-v1"
 )
 
-// New creates middleware with configure for this
+// cors is the CORS policy compiled from Config.
+// It is shared by the goa middleware and the net/http middleware.
+type cors struct {
+	service               *goa.Service
+	logLevel              LogLevel
+	metrics               bool
+	originLabels          *originLabeler
+	skipper               Skipper
+	schemes               []string
+	allowAnyOrigin        bool
+	denyOrigins           []Pattern
+	rules                 []*originRule
+	allowOriginFunc       AllowOriginFunc
+	policy                *policy
+	routes                *RouteRecorder
+	reflectRequestHeaders bool
+	allowPrivateNetwork   bool
+	preflightRejectStatus int
+	onReject              RejectFunc
+	optionsPassthrough    bool
+	strict                bool
+	strictError           goa.ErrorClass
+	strictMessage         string
+	reportOnly            *cors
+	onDivergence          DivergenceFunc
+}
+
+// New creates middleware with configure for this.
+// It panics if the allowed origins are invalid, or "*" is used with credentials without UnsafeAllowAnyOriginWithCredentials.
+// Use NewWithError to validate the configure.
 func New(service *goa.Service, conf *Config) goa.Middleware {
+	c := newCORS(service, conf)
+	return func(next goa.Handler) goa.Handler {
+		return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+			ctx, done, err := c.handle(ctx, rw, req)
+			if err != nil {
+				return err
+			}
+			if done {
+				return nil
+			}
+			return next(ctx, rw, req)
+		}
+	}
+}
+
+// NewWithError creates middleware with configure for this.
+// Unlike New, it validates the configure by Config.Validate and returns the error instead of panicking.
+func NewWithError(service *goa.Service, conf *Config) (goa.Middleware, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	return New(service, conf), nil
+}
+
+func newCORS(service *goa.Service, conf *Config) *cors {
+	defaultPolicy := newPolicy(conf.defaultPolicy())
+
 	// validate allowed origin configure
 	allowAnyOrigin := false
-	allowOrigins := make([]originType, len(conf.AllowOrigins))
-	for i, origin := range conf.AllowOrigins {
+	for _, origin := range conf.AllowOrigins {
 		if origin == "*" {
 			allowAnyOrigin = true
-			break
 		}
-		o, err := parseOrigin(origin)
-		if err != nil {
-			panic("invalid allowed origin: " + origin)
+	}
+	if len(conf.unsafeCredentials()) > 0 {
+		if !conf.UnsafeAllowAnyOriginWithCredentials {
+			panic("goacors: " + errUnsafeCredentials.Error())
+		}
+		if service != nil {
+			service.LogError("cors: UnsafeAllowAnyOriginWithCredentials is enabled. any origin can make credentialed requests")
 		}
-		allowOrigins[i] = o
 	}
 
-	skipper := conf.Skipper
-	allowMethods := strings.Join(conf.AllowMethods, ", ")
-	allowHeaders := strings.Join(conf.AllowHeaders, ", ")
-	exposeHeaders := strings.Join(conf.ExposeHeaders, ", ")
-	allowCredentials := conf.AllowCredentials
-	var maxAge string
-	if conf.MaxAge > 0 {
-		maxAge = strconv.Itoa(conf.MaxAge)
+	denyOrigins := make([]Pattern, 0, len(conf.DenyOrigins))
+	for _, origin := range conf.DenyOrigins {
+		p, err := ParsePattern(origin, conf.AllowSchemes...)
+		if err != nil {
+			panic("invalid denied origin: " + origin)
+		}
+		denyOrigins = append(denyOrigins, p)
 	}
 
-	return func(next goa.Handler) goa.Handler {
-		return func(c context.Context, rw http.ResponseWriter, req *http.Request) error {
-			// Skipper
-			if skipper != nil && skipper(c, rw, req) {
-				return next(c, rw, req)
+	var rules []*originRule
+	addRules := func(origins, patterns []string, p *policy) {
+		for _, origin := range origins {
+			if origin == "*" {
+				continue
 			}
-
-			h := rw.Header()
-
-			// Check the origin of the request is allowed
-			var allowedOrigin string
-			if allowAnyOrigin {
-				if allowCredentials {
-					// https://developer.mozilla.org/en-US/docs/Web/HTTP/CORS
-					// When responding to a credentialed request, the server must specify an origin in the value of
-					// the Access-Control-Allow-Origin header, instead of specifying the "*" wildcard.
-					allowedOrigin = req.Header.Get(HeaderOrigin)
-				} else {
-					allowedOrigin = "*"
-				}
-			} else {
-				origin := req.Header.Get(HeaderOrigin)
-				if allowed(origin, allowOrigins, allowCredentials) {
-					allowedOrigin = origin
-				}
+			pattern, err := ParsePattern(origin, conf.AllowSchemes...)
+			if err != nil {
+				panic("invalid allowed origin: " + origin)
 			}
-
-			if req.Method != http.MethodOptions {
-				// handle normal requests
-				h.Add(HeaderVary, HeaderOrigin)
-				if allowedOrigin != "" {
-					h.Set(HeaderAccessControlAllowOrigin, allowedOrigin)
-				}
-				if allowCredentials {
-					h.Set(HeaderAccessControlAllowCredentials, "true")
-				}
-				if exposeHeaders != "" {
-					h.Set(HeaderAccessControlExposeHeaders, exposeHeaders)
-				}
-				return next(c, rw, req)
+			if pattern.hasPortRange() && !pattern.isLoopback() && !conf.AllowPortWildcardsOnAnyHost {
+				panic("port wildcards are allowed only on loopback hosts: " + origin)
 			}
-
-			// handle preflight requests
-			h.Add(HeaderVary, HeaderOrigin)
-			h.Add(HeaderVary, HeaderAccessControlRequestMethod)
-			h.Add(HeaderVary, HeaderAccessControlRequestHeaders)
-			h.Set(HeaderAccessControlAllowOrigin, allowedOrigin)
-			h.Set(HeaderAccessControlAllowMethods, allowMethods)
-			if allowCredentials {
-				h.Set(HeaderAccessControlAllowCredentials, "true")
+			rules = append(rules, newPatternRule(origin, pattern, p))
+		}
+		for _, pattern := range patterns {
+			m, err := MatchRegexp(pattern)
+			if err != nil {
+				panic("invalid allowed origin pattern: " + pattern)
 			}
-			if allowHeaders != "" {
-				h.Set(HeaderAccessControlAllowHeaders, allowHeaders)
+			rules = append(rules, newMatcherRule(m, p))
+		}
+	}
+	// the first rule wins the tie, so the entries of OriginPolicies are added before the default policy.
+	for _, op := range conf.OriginPolicies {
+		p := newPolicy(op.Policy)
+		addRules(op.Origins, op.OriginPatterns, p)
+		if op.Matcher != nil {
+			rules = append(rules, newMatcherRule(op.Matcher, p))
+		}
+	}
+	if !allowAnyOrigin {
+		// any origin is allowed by the default policy, so the entries are meaningless.
+		addRules(conf.AllowOrigins, conf.AllowOriginPatterns, defaultPolicy)
+	}
+	if conf.AllowOriginMatcher != nil && !allowAnyOrigin {
+		// the matcher is the least specific, so the entries of OriginPolicies win over it.
+		rules = append(rules, newMatcherRule(conf.AllowOriginMatcher, defaultPolicy))
+	}
+
+	preflightRejectStatus := conf.PreflightRejectStatus
+	if preflightRejectStatus == 0 {
+		preflightRejectStatus = http.StatusNoContent
+	}
+	if preflightRejectStatus < 200 || preflightRejectStatus > 599 {
+		panic("invalid preflight reject status: " + strconv.Itoa(preflightRejectStatus))
+	}
+
+	strictErrorID := conf.StrictErrorID
+	if strictErrorID == "" {
+		strictErrorID = "cors_origin_not_allowed"
+	}
+	strictMessage := conf.StrictErrorMessage
+	if strictMessage == "" {
+		strictMessage = "origin is not allowed"
+	}
+
+	var routes *RouteRecorder
+	if conf.AllowMethodsFromMux && service != nil {
+		routes = RecordRoutes(service)
+	}
+
+	var reportOnly *cors
+	if conf.ReportOnly != nil {
+		reportOnly = newCORS(service, conf.ReportOnly)
+
+		// the candidate is used only for the evaluation
+		reportOnly.logLevel = LogLevelNone
+		reportOnly.metrics = false
+		reportOnly.reportOnly = nil
+	}
+
+	return &cors{
+		service:               service,
+		logLevel:              conf.LogLevel,
+		metrics:               conf.EnableMetrics,
+		originLabels:          newOriginLabeler(conf.MetricsMaxOrigins, conf.AllowSchemes),
+		skipper:               conf.Skipper,
+		schemes:               conf.AllowSchemes,
+		allowAnyOrigin:        allowAnyOrigin,
+		denyOrigins:           denyOrigins,
+		rules:                 rules,
+		allowOriginFunc:       conf.AllowOriginFunc,
+		policy:                defaultPolicy,
+		routes:                routes,
+		reflectRequestHeaders: conf.ReflectRequestHeaders,
+		allowPrivateNetwork:   conf.AllowPrivateNetwork,
+		preflightRejectStatus: preflightRejectStatus,
+		onReject:              conf.OnReject,
+		optionsPassthrough:    conf.OptionsPassthrough,
+		strict:                conf.Strict,
+		strictError:           goa.NewErrorClass(strictErrorID, http.StatusForbidden),
+		strictMessage:         strictMessage,
+		reportOnly:            reportOnly,
+		onDivergence:          conf.OnDivergence,
+	}
+}
+
+// handle sets the CORS headers to the response.
+// It returns the context that holds the decision, and
+// true if the response is completed, and the next handler should not be called.
+func (c *cors) handle(ctx context.Context, rw http.ResponseWriter, req *http.Request) (context.Context, bool, error) {
+	// Skipper
+	if c.skipper != nil && c.skipper(ctx, rw, req) {
+		c.logInfo(ctx, LogLevelDebug, "cors skipped", "method", req.Method, "path", req.URL.Path)
+		c.incrSkipped()
+		return ctx, false, nil
+	}
+
+	d, err := c.evaluate(ctx, req)
+	if err != nil {
+		return ctx, false, err
+	}
+	c.report(ctx, d)
+	if c.onReject != nil && d.Reason != "" {
+		c.onReject(ctx, req, d)
+	}
+	if c.reportOnly != nil {
+		c.compareReportOnly(ctx, req, d)
+	}
+	ctx = withDecision(ctx, d)
+	if c.write(rw, d) {
+		return ctx, true, nil
+	}
+	if c.strict && d.Reason != "" {
+		return ctx, true, c.reject(ctx, rw)
+	}
+	return ctx, false, nil
+}
+
+// evaluate decides whether the request is allowed.
+func (c *cors) evaluate(ctx context.Context, req *http.Request) (*Decision, error) {
+	d := &Decision{
+		Origin:    req.Header.Get(HeaderOrigin),
+		Preflight: isPreflight(req),
+	}
+	o, err := ParseOrigin(d.Origin, c.schemes...)
+	valid := err == nil
+	if valid {
+		d.NormalizedOrigin = o.String()
+	}
+
+	// Check the origin of the request is denied
+	p := c.policy
+	if valid && c.denied(o) {
+		d.policy = p
+		d.Reason = ReasonOriginDenied
+		return d, nil
+	}
+
+	// Check the origin of the request is allowed
+	var rule *originRule
+	if valid {
+		rule = matchRules(o, c.rules)
+	}
+	if rule != nil {
+		d.AllowedOrigin = d.Origin
+		d.MatchedOrigin = rule.source
+		p = rule.policy
+	} else if c.allowAnyOrigin {
+		if p.allowCredentials {
+			// https://developer.mozilla.org/en-US/docs/Web/HTTP/CORS
+			// When responding to a credentialed request, the server must specify an origin in the value of
+			// the Access-Control-Allow-Origin header, instead of specifying the "*" wildcard.
+			d.AllowedOrigin = d.Origin
+		} else {
+			d.AllowedOrigin = "*"
+		}
+		d.MatchedOrigin = "*"
+	} else if c.allowOriginFunc != nil && d.Origin != "" {
+		// fallback to the callback
+		ok, err := c.allowOriginFunc(ctx, d.Origin, req)
+		if err != nil {
+			c.logError(ctx, LogLevelRejected, "cors AllowOriginFunc failed", "origin", d.Origin, "err", err)
+			return nil, err
+		}
+		if ok {
+			d.AllowedOrigin = d.Origin
+		}
+	}
+	d.policy = p
+	d.Credentialed = p.allowCredentials && d.AllowedOrigin != ""
+
+	if !d.Preflight {
+		if d.AllowedOrigin == "" && d.Origin != "" {
+			if c.sameOrigin(req, d.Origin) {
+				// browsers send the Origin header in same-origin POST requests, but they are not CORS requests.
+				d.sameOrigin = true
 			} else {
-				header := req.Header.Get(HeaderAccessControlRequestHeaders)
-				if header != "" {
-					h.Set(HeaderAccessControlAllowHeaders, header)
-				}
+				d.Reason = ReasonOriginNotAllowed
 			}
+		}
+		return d, nil
+	}
 
-			if maxAge != "" {
-				h.Set(HeaderAccessControlMaxAge, maxAge)
-			}
-			rw.WriteHeader(http.StatusNoContent)
-			return nil
+	d.Method = req.Header.Get(HeaderAccessControlRequestMethod)
+	if d.AllowedOrigin == "" {
+		// the origin is checked first, so the reason is not hidden by the method and the headers.
+		d.Reason = ReasonOriginNotAllowed
+		return d, nil
+	}
+
+	// check the requested method is allowed
+	if c.routes != nil {
+		allowMethodList := c.routeMethods(req.URL.Path, p)
+		d.allowMethods = strings.Join(allowMethodList, ", ")
+		// the path without any route allows no method, even the safelisted ones
+		if len(allowMethodList) == 0 || !allowedMethod(d.Method, allowMethodList) {
+			d.Reason = ReasonMethodNotAllowed
+			return d, nil
+		}
+	} else if p.allowAnyMethod {
+		if p.allowCredentials {
+			d.allowMethods = p.expandMethods(d.Method)
+		} else {
+			d.allowMethods = p.allowMethods
+		}
+	} else {
+		d.allowMethods = p.allowMethods
+		if !allowedMethod(d.Method, p.allowMethodList) {
+			d.Reason = ReasonMethodNotAllowed
+			return d, nil
+		}
+	}
+
+	// https://wicg.github.io/private-network-access/#cors-preflight
+	d.PrivateNetwork = req.Header.Get(HeaderAccessControlRequestPrivateNetwork) == "true"
+
+	// check the requested headers are allowed
+	values := req.Header.Values(HeaderAccessControlRequestHeaders)
+	requestHeaders, ok := parseHeaderList(values)
+	if !ok {
+		// keep the raw values for logging
+		d.RequestHeaders = values
+		d.Reason = ReasonHeadersNotAllowed
+		return d, nil
+	}
+	d.RequestHeaders = requestHeaders
+	if !c.reflectRequestHeaders && !p.allowedHeaders(requestHeaders) {
+		d.Reason = ReasonHeadersNotAllowed
+	}
+	return d, nil
+}
+
+// denied reports whether the origin matches DenyOrigins.
+func (c *cors) denied(o Origin) bool {
+	for _, p := range c.denyOrigins {
+		if p.Match(o) {
+			return true
+		}
+	}
+	return false
+}
+
+// report logs the decision and records it into the metrics.
+func (c *cors) report(ctx context.Context, d *Decision) {
+	if (d.Origin == "" || d.sameOrigin) && d.Reason == "" {
+		// the Origin header is missing or the request is same-origin, so it is not a CORS request
+		return
+	}
+	c.incrDecision(d)
+
+	if !d.Preflight {
+		if d.Reason != "" {
+			c.logInfo(ctx, LogLevelRejected, "cors rejected", "reason", string(d.Reason), "origin", d.Origin)
+		} else {
+			c.logInfo(ctx, LogLevelDebug, "cors allowed", "origin", d.Origin)
+		}
+		return
+	}
+
+	switch d.Reason {
+	case "":
+		c.logInfo(ctx, LogLevelDebug, "cors preflight allowed", "origin", d.Origin, "method", d.Method)
+	case ReasonMethodNotAllowed:
+		c.logInfo(ctx, LogLevelRejected, "cors preflight rejected", "reason", string(d.Reason), "origin", d.Origin, "method", d.Method)
+	case ReasonHeadersNotAllowed:
+		headers := strings.Join(d.RequestHeaders, ", ")
+		c.logInfo(ctx, LogLevelRejected, "cors preflight rejected", "reason", string(d.Reason), "origin", d.Origin, "headers", headers)
+	default:
+		c.logInfo(ctx, LogLevelRejected, "cors preflight rejected", "reason", string(d.Reason), "origin", d.Origin)
+	}
+}
+
+// write writes the CORS headers to the response.
+// It returns true if the response is completed, and the next handler should not be called.
+func (c *cors) write(rw http.ResponseWriter, d *Decision) bool {
+	h := rw.Header()
+	p := d.policy
+
+	if !d.Preflight {
+		// handle normal requests
+		h.Add(HeaderVary, HeaderOrigin)
+		if d.AllowedOrigin == "" {
+			// the Origin header is missing, or the origin is not allowed.
+			return false
+		}
+		h.Set(HeaderAccessControlAllowOrigin, d.AllowedOrigin)
+		if p.allowCredentials {
+			h.Set(HeaderAccessControlAllowCredentials, "true")
+		}
+		if p.exposeHeaders != "" {
+			h.Set(HeaderAccessControlExposeHeaders, p.exposeHeaders)
+		}
+		return false
+	}
+
+	// handle preflight requests
+	h.Add(HeaderVary, HeaderOrigin)
+	h.Add(HeaderVary, HeaderAccessControlRequestMethod)
+	h.Add(HeaderVary, HeaderAccessControlRequestHeaders)
+	if c.allowPrivateNetwork {
+		h.Add(HeaderVary, HeaderAccessControlRequestPrivateNetwork)
+	}
+
+	if d.Reason != "" {
+		// no Access-Control-Allow-* headers are sent, so the browser rejects the actual request.
+		if c.optionsPassthrough {
+			return false
+		}
+		rw.WriteHeader(c.preflightRejectStatus)
+		return true
+	}
+
+	h.Set(HeaderAccessControlAllowOrigin, d.AllowedOrigin)
+	h.Set(HeaderAccessControlAllowMethods, d.allowMethods)
+	if p.allowCredentials {
+		h.Set(HeaderAccessControlAllowCredentials, "true")
+	}
+	if c.reflectRequestHeaders {
+		if len(d.RequestHeaders) > 0 {
+			h.Set(HeaderAccessControlAllowHeaders, strings.Join(d.RequestHeaders, ", "))
 		}
+	} else if p.allowAnyHeader && p.allowCredentials {
+		if headers := p.expandHeaders(d.RequestHeaders); headers != "" {
+			h.Set(HeaderAccessControlAllowHeaders, headers)
+		}
+	} else if p.allowHeaders != "" {
+		h.Set(HeaderAccessControlAllowHeaders, p.allowHeaders)
+	}
+
+	if c.allowPrivateNetwork && d.PrivateNetwork {
+		h.Set(HeaderAccessControlAllowPrivateNetwork, "true")
+	}
+
+	if p.maxAge != "" {
+		h.Set(HeaderAccessControlMaxAge, p.maxAge)
+	}
+	if c.optionsPassthrough {
+		return false
 	}
+	rw.WriteHeader(http.StatusNoContent)
+	return true
+}
 
+// isPreflight reports whether the request is a CORS-preflight request.
+// https://fetch.spec.whatwg.org/#cors-preflight-request
+// > A CORS-preflight request is a CORS request that checks to see if the CORS protocol is understood.
+// > It uses `OPTIONS` as method and includes the `Access-Control-Request-Method` header.
+// The other OPTIONS requests are handled as actual requests.
+func isPreflight(req *http.Request) bool {
+	return req.Method == http.MethodOptions &&
+		req.Header.Get(HeaderOrigin) != "" &&
+		req.Header.Get(HeaderAccessControlRequestMethod) != ""
+}
+
+// https://fetch.spec.whatwg.org/#cors-safelisted-method
+// > A CORS-safelisted method is a method that is `GET`, `HEAD`, or `POST`.
+func isSafelistedMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodPost
+}
+
+func allowedMethod(method string, allowMethods []string) bool {
+	if isSafelistedMethod(method) {
+		return true
+	}
+
+	// methods are case-sensitive
+	for _, m := range allowMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
 }
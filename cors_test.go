@@ -3,8 +3,10 @@ package goacors_test
 import (
 	"context"
 	"net/http"
+	"strings"
 	"testing"
 
+	"github.com/shogo82148/goa-v1"
 	"github.com/shogo82148/goacors-v1"
 )
 
@@ -62,8 +64,9 @@ func TestOriginAllowsWildcard(t *testing.T) {
 		return service.Send(ctx, http.StatusOK, "ok")
 	}
 	testee := goacors.New(service, &goacors.Config{
-		AllowCredentials: true,
-		AllowOrigins:     []string{"*"},
+		AllowCredentials:                    true,
+		AllowOrigins:                        []string{"*"},
+		UnsafeAllowAnyOriginWithCredentials: true,
 	})(h)
 	err := testee(ctx, rw, req)
 	if err != nil {
@@ -74,6 +77,53 @@ func TestOriginAllowsWildcard(t *testing.T) {
 	}
 }
 
+func TestOriginAllowsWildcardWithCredentials(t *testing.T) {
+	service := newService(nil)
+	defer func() {
+		if recover() == nil {
+			t.Error("it should panic")
+		}
+	}()
+	goacors.New(service, &goacors.Config{
+		AllowCredentials: true,
+		AllowOrigins:     []string{"*"},
+	})
+}
+
+func TestOriginPolicyMatchAnyWithCredentials(t *testing.T) {
+	service := newService(nil)
+	defer func() {
+		if recover() == nil {
+			t.Error("it should panic")
+		}
+	}()
+	goacors.New(service, &goacors.Config{
+		AllowOrigins: []string{"http://example.com"},
+		OriginPolicies: []goacors.OriginPolicy{
+			{
+				Matcher: goacors.AnyOf(goacors.MatchAny()),
+				Policy: goacors.Policy{
+					AllowMethods:     []string{http.MethodGet},
+					AllowCredentials: true,
+				},
+			},
+		},
+	})
+}
+
+func TestOriginAllowsWildcardWithCredentialsWarning(t *testing.T) {
+	logger := &testLogger{}
+	service := newService(logger)
+	goacors.New(service, &goacors.Config{
+		AllowCredentials:                    true,
+		AllowOrigins:                        []string{"*"},
+		UnsafeAllowAnyOriginWithCredentials: true,
+	})
+	if len(logger.ErrorEntries) != 1 {
+		t.Fatalf("want 1 warning, got %d", len(logger.ErrorEntries))
+	}
+}
+
 func TestOrigIsNotValid(t *testing.T) {
 	service := newService(nil)
 	req, _ := http.NewRequest(http.MethodGet, "/", nil)
@@ -130,6 +180,7 @@ func TestPreflightRequest(t *testing.T) {
 	fixedOrigin := "http://localhost"
 	req, _ := http.NewRequest(http.MethodOptions, "/", nil)
 	req.Header.Set(goacors.HeaderOrigin, fixedOrigin)
+	req.Header.Set(goacors.HeaderAccessControlRequestMethod, http.MethodPut)
 	req.Header.Set(goacors.HeaderAccessControlRequestHeaders, "X-OriginalRequest")
 	req.Header.Set(goacors.HeaderContentType, "application/json")
 	rw := newTestResponseWriter()
@@ -139,10 +190,11 @@ func TestPreflightRequest(t *testing.T) {
 		return service.Send(ctx, http.StatusOK, "ok")
 	}
 	testee := goacors.New(service, &goacors.Config{
-		AllowOrigins:     []string{fixedOrigin},
-		AllowMethods:     []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPatch, http.MethodPost, http.MethodDelete},
-		MaxAge:           3600,
-		AllowCredentials: true,
+		AllowOrigins:          []string{fixedOrigin},
+		AllowMethods:          []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPatch, http.MethodPost, http.MethodDelete},
+		MaxAge:                3600,
+		AllowCredentials:      true,
+		ReflectRequestHeaders: true,
 	})(h)
 	err := testee(ctx, rw, req)
 	if err != nil {
@@ -251,6 +303,7 @@ func TestAddedAllowOrigHeader(t *testing.T) {
 	service := newService(nil)
 	req, _ := http.NewRequest(http.MethodOptions, "/", nil)
 	req.Header.Set(goacors.HeaderOrigin, "http://someorigin.com")
+	req.Header.Set(goacors.HeaderAccessControlRequestMethod, http.MethodGet)
 	rw := newTestResponseWriter()
 	ctx := newContext(service, rw, req, nil)
 
@@ -258,6 +311,7 @@ func TestAddedAllowOrigHeader(t *testing.T) {
 		return service.Send(ctx, http.StatusOK, "ok")
 	}
 	testee := goacors.New(service, &goacors.Config{
+		AllowOrigins:     []string{"http://someorigin.com"},
 		AllowCredentials: true,
 		AllowHeaders:     []string{"X-OrigHeader"},
 	})(h)
@@ -278,3 +332,1077 @@ func TestAddedAllowOrigHeader(t *testing.T) {
 		t.Log(string(rw.Body))
 	}
 }
+
+func TestPreflightRequestWithAllowedMethod(t *testing.T) {
+	service := newService(nil)
+	fixedOrigin := "http://localhost"
+	req, _ := http.NewRequest(http.MethodOptions, "/", nil)
+	req.Header.Set(goacors.HeaderOrigin, fixedOrigin)
+	req.Header.Set(goacors.HeaderAccessControlRequestMethod, http.MethodPut)
+	rw := newTestResponseWriter()
+	ctx := newContext(service, rw, req, nil)
+
+	h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+		return service.Send(ctx, http.StatusOK, "ok")
+	}
+	testee := goacors.New(service, &goacors.Config{
+		AllowOrigins: []string{fixedOrigin},
+		AllowMethods: []string{http.MethodGet, http.MethodPut},
+	})(h)
+	err := testee(ctx, rw, req)
+	if err != nil {
+		t.Error("it should not return any error but ", err)
+	}
+	if rw.Header().Get(goacors.HeaderAccessControlAllowOrigin) != fixedOrigin {
+		t.Errorf("allow origin should be %s but %s", fixedOrigin, rw.Header().Get(goacors.HeaderAccessControlAllowOrigin))
+	}
+	if rw.Header().Get(goacors.HeaderAccessControlAllowMethods) != "GET, PUT" {
+		t.Errorf("allow method should be %q but %q", "GET, PUT", rw.Header().Get(goacors.HeaderAccessControlAllowMethods))
+	}
+	if rw.Status != http.StatusNoContent {
+		t.Errorf("the status should be %d, got %d", http.StatusNoContent, rw.Status)
+	}
+}
+
+func TestPreflightRequestWithSafelistedMethod(t *testing.T) {
+	service := newService(nil)
+	fixedOrigin := "http://localhost"
+	req, _ := http.NewRequest(http.MethodOptions, "/", nil)
+	req.Header.Set(goacors.HeaderOrigin, fixedOrigin)
+	req.Header.Set(goacors.HeaderAccessControlRequestMethod, http.MethodPost)
+	rw := newTestResponseWriter()
+	ctx := newContext(service, rw, req, nil)
+
+	h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+		return service.Send(ctx, http.StatusOK, "ok")
+	}
+	testee := goacors.New(service, &goacors.Config{
+		AllowOrigins: []string{fixedOrigin},
+		AllowMethods: []string{http.MethodPut},
+	})(h)
+	err := testee(ctx, rw, req)
+	if err != nil {
+		t.Error("it should not return any error but ", err)
+	}
+	if rw.Header().Get(goacors.HeaderAccessControlAllowOrigin) != fixedOrigin {
+		t.Errorf("allow origin should be %s but %s", fixedOrigin, rw.Header().Get(goacors.HeaderAccessControlAllowOrigin))
+	}
+	if rw.Status != http.StatusNoContent {
+		t.Errorf("the status should be %d, got %d", http.StatusNoContent, rw.Status)
+	}
+}
+
+func TestPreflightRequestWithDisallowedMethod(t *testing.T) {
+	service := newService(nil)
+	fixedOrigin := "http://localhost"
+	req, _ := http.NewRequest(http.MethodOptions, "/", nil)
+	req.Header.Set(goacors.HeaderOrigin, fixedOrigin)
+	req.Header.Set(goacors.HeaderAccessControlRequestMethod, http.MethodDelete)
+	rw := newTestResponseWriter()
+	ctx := newContext(service, rw, req, nil)
+
+	h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+		return service.Send(ctx, http.StatusOK, "ok")
+	}
+	testee := goacors.New(service, &goacors.Config{
+		AllowOrigins:          []string{fixedOrigin},
+		AllowMethods:          []string{http.MethodGet, http.MethodPut},
+		AllowCredentials:      true,
+		MaxAge:                3600,
+		PreflightRejectStatus: http.StatusForbidden,
+	})(h)
+	err := testee(ctx, rw, req)
+	if err != nil {
+		t.Error("it should not return any error but ", err)
+	}
+	for _, name := range []string{
+		goacors.HeaderAccessControlAllowOrigin,
+		goacors.HeaderAccessControlAllowMethods,
+		goacors.HeaderAccessControlAllowCredentials,
+		goacors.HeaderAccessControlMaxAge,
+	} {
+		if v := rw.Header().Get(name); v != "" {
+			t.Errorf("%s should be empty but %q", name, v)
+		}
+	}
+	if rw.Status != http.StatusForbidden {
+		t.Errorf("the status should be %d, got %d", http.StatusForbidden, rw.Status)
+	}
+	if len(rw.Body) != 0 {
+		t.Errorf("the length of the body should be 0, got %d", len(rw.Body))
+	}
+}
+
+func TestPreflightRequestWithAllowedHeaders(t *testing.T) {
+	service := newService(nil)
+	fixedOrigin := "http://localhost"
+	req, _ := http.NewRequest(http.MethodOptions, "/", nil)
+	req.Header.Set(goacors.HeaderOrigin, fixedOrigin)
+	req.Header.Set(goacors.HeaderAccessControlRequestMethod, http.MethodPut)
+	req.Header.Add(goacors.HeaderAccessControlRequestHeaders, "x-foo, content-type")
+	req.Header.Add(goacors.HeaderAccessControlRequestHeaders, "x-bar")
+	rw := newTestResponseWriter()
+	ctx := newContext(service, rw, req, nil)
+
+	h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+		return service.Send(ctx, http.StatusOK, "ok")
+	}
+	testee := goacors.New(service, &goacors.Config{
+		AllowOrigins: []string{fixedOrigin},
+		AllowMethods: []string{http.MethodPut},
+		AllowHeaders: []string{"X-Foo", "X-Bar", "Content-Type"},
+	})(h)
+	err := testee(ctx, rw, req)
+	if err != nil {
+		t.Error("it should not return any error but ", err)
+	}
+	if rw.Header().Get(goacors.HeaderAccessControlAllowOrigin) != fixedOrigin {
+		t.Errorf("allow origin should be %s but %s", fixedOrigin, rw.Header().Get(goacors.HeaderAccessControlAllowOrigin))
+	}
+	if rw.Header().Get(goacors.HeaderAccessControlAllowHeaders) != "X-Foo, X-Bar, Content-Type" {
+		t.Errorf("allow headers should be %q but %q", "X-Foo, X-Bar, Content-Type", rw.Header().Get(goacors.HeaderAccessControlAllowHeaders))
+	}
+	if rw.Status != http.StatusNoContent {
+		t.Errorf("the status should be %d, got %d", http.StatusNoContent, rw.Status)
+	}
+}
+
+func TestPreflightRequestWithDisallowedHeaders(t *testing.T) {
+	testcases := []struct {
+		name    string
+		headers string
+	}{
+		{
+			name:    "unlisted header",
+			headers: "x-foo, x-unknown",
+		},
+		{
+			name:    "invalid token",
+			headers: "x-foo, x foo",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			service := newService(nil)
+			fixedOrigin := "http://localhost"
+			req, _ := http.NewRequest(http.MethodOptions, "/", nil)
+			req.Header.Set(goacors.HeaderOrigin, fixedOrigin)
+			req.Header.Set(goacors.HeaderAccessControlRequestMethod, http.MethodPut)
+			req.Header.Set(goacors.HeaderAccessControlRequestHeaders, tc.headers)
+			rw := newTestResponseWriter()
+			ctx := newContext(service, rw, req, nil)
+
+			h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+				return service.Send(ctx, http.StatusOK, "ok")
+			}
+			testee := goacors.New(service, &goacors.Config{
+				AllowOrigins: []string{fixedOrigin},
+				AllowMethods: []string{http.MethodPut},
+				AllowHeaders: []string{"X-Foo"},
+			})(h)
+			err := testee(ctx, rw, req)
+			if err != nil {
+				t.Error("it should not return any error but ", err)
+			}
+			if v := rw.Header().Get(goacors.HeaderAccessControlAllowOrigin); v != "" {
+				t.Errorf("allow origin should be empty but %q", v)
+			}
+			if v := rw.Header().Get(goacors.HeaderAccessControlAllowHeaders); v != "" {
+				t.Errorf("allow headers should be empty but %q", v)
+			}
+		})
+	}
+}
+
+func TestOriginAllowsPattern(t *testing.T) {
+	service := newService(nil)
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(goacors.HeaderOrigin, "https://pr-1234.preview.example.net")
+	rw := newTestResponseWriter()
+	ctx := newContext(service, rw, req, nil)
+
+	h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+		return service.Send(ctx, http.StatusOK, "ok")
+	}
+	testee := goacors.New(service, &goacors.Config{
+		AllowOrigins:        []string{"https://example.net"},
+		AllowOriginPatterns: []string{`https://pr-[0-9]+\.preview\.example\.net`},
+	})(h)
+	err := testee(ctx, rw, req)
+	if err != nil {
+		t.Error("it should not return any error but ", err)
+	}
+	if rw.Header().Get(goacors.HeaderAccessControlAllowOrigin) != "https://pr-1234.preview.example.net" {
+		t.Errorf("allow origin should be %s but %s", "https://pr-1234.preview.example.net", rw.Header().Get(goacors.HeaderAccessControlAllowOrigin))
+	}
+}
+
+func TestInvalidPreflightRejectStatus(t *testing.T) {
+	service := newService(nil)
+	defer func() {
+		if recover() == nil {
+			t.Error("it should panic")
+		}
+	}()
+	goacors.New(service, &goacors.Config{
+		AllowOrigins:          []string{"http://example.com"},
+		PreflightRejectStatus: 1000,
+	})
+}
+
+func TestInvalidOriginPattern(t *testing.T) {
+	service := newService(nil)
+	defer func() {
+		if recover() == nil {
+			t.Error("it should panic")
+		}
+	}()
+	goacors.New(service, &goacors.Config{
+		AllowOriginPatterns: []string{`https://(.preview\.example\.net`},
+	})
+}
+
+func TestOriginAllowsFunc(t *testing.T) {
+	testcases := []struct {
+		origin string
+		want   string
+	}{
+		{
+			origin: "https://tenant.example.com",
+			want:   "https://tenant.example.com",
+		},
+		{
+			origin: "https://static.example.com",
+			want:   "https://static.example.com",
+		},
+		{
+			origin: "https://unknown.example.com",
+			want:   "",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.origin, func(t *testing.T) {
+			service := newService(nil)
+			req, _ := http.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(goacors.HeaderOrigin, tc.origin)
+			rw := newTestResponseWriter()
+			ctx := newContext(service, rw, req, nil)
+
+			h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+				return service.Send(ctx, http.StatusOK, "ok")
+			}
+			var called []string
+			testee := goacors.New(service, &goacors.Config{
+				AllowOrigins: []string{"https://static.example.com"},
+				AllowOriginFunc: func(ctx context.Context, origin string, req *http.Request) (bool, error) {
+					called = append(called, origin)
+					return origin == "https://tenant.example.com", nil
+				},
+			})(h)
+			err := testee(ctx, rw, req)
+			if err != nil {
+				t.Error("it should not return any error but ", err)
+			}
+			if got := rw.Header().Get(goacors.HeaderAccessControlAllowOrigin); got != tc.want {
+				t.Errorf("allow origin should be %q but %q", tc.want, got)
+			}
+			if tc.origin == "https://static.example.com" && len(called) != 0 {
+				t.Errorf("AllowOriginFunc should not be called for static origins, but called with %v", called)
+			}
+		})
+	}
+}
+
+func TestOriginAllowsFuncError(t *testing.T) {
+	service := newService(nil)
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(goacors.HeaderOrigin, "https://tenant.example.com")
+	rw := newTestResponseWriter()
+	ctx := newContext(service, rw, req, nil)
+
+	var nextCalled bool
+	h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+		nextCalled = true
+		return service.Send(ctx, http.StatusOK, "ok")
+	}
+	wantErr := goa.ErrInternal("database is down")
+	testee := goacors.New(service, &goacors.Config{
+		AllowOriginFunc: func(ctx context.Context, origin string, req *http.Request) (bool, error) {
+			return false, wantErr
+		},
+	})(h)
+	err := testee(ctx, rw, req)
+	if err != wantErr {
+		t.Errorf("it should return %v but %v", wantErr, err)
+	}
+	if nextCalled {
+		t.Error("next handler should not be called")
+	}
+}
+
+func TestPreflightRequestPrivateNetwork(t *testing.T) {
+	testcases := []struct {
+		name      string
+		allow     bool
+		requested string
+		want      string
+		vary      bool
+	}{
+		{
+			name:      "allowed",
+			allow:     true,
+			requested: "true",
+			want:      "true",
+			vary:      true,
+		},
+		{
+			name:      "not requested",
+			allow:     true,
+			requested: "",
+			want:      "",
+			vary:      true,
+		},
+		{
+			name:      "not allowed",
+			allow:     false,
+			requested: "true",
+			want:      "",
+			vary:      false,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			service := newService(nil)
+			fixedOrigin := "http://localhost"
+			req, _ := http.NewRequest(http.MethodOptions, "/", nil)
+			req.Header.Set(goacors.HeaderOrigin, fixedOrigin)
+			req.Header.Set(goacors.HeaderAccessControlRequestMethod, http.MethodGet)
+			if tc.requested != "" {
+				req.Header.Set(goacors.HeaderAccessControlRequestPrivateNetwork, tc.requested)
+			}
+			rw := newTestResponseWriter()
+			ctx := newContext(service, rw, req, nil)
+
+			h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+				return service.Send(ctx, http.StatusOK, "ok")
+			}
+			testee := goacors.New(service, &goacors.Config{
+				AllowOrigins:        []string{fixedOrigin},
+				AllowMethods:        []string{http.MethodGet},
+				AllowPrivateNetwork: tc.allow,
+			})(h)
+			err := testee(ctx, rw, req)
+			if err != nil {
+				t.Error("it should not return any error but ", err)
+			}
+			if v := rw.Header().Get(goacors.HeaderAccessControlAllowPrivateNetwork); v != tc.want {
+				t.Errorf("allow private network should be %q but %q", tc.want, v)
+			}
+			vary := false
+			for _, v := range rw.Header().Values(goacors.HeaderVary) {
+				if v == goacors.HeaderAccessControlRequestPrivateNetwork {
+					vary = true
+				}
+			}
+			if vary != tc.vary {
+				t.Errorf("vary %s should be %v but %v", goacors.HeaderAccessControlRequestPrivateNetwork, tc.vary, vary)
+			}
+			if rw.Status != http.StatusNoContent {
+				t.Errorf("the status should be %d, got %d", http.StatusNoContent, rw.Status)
+			}
+		})
+	}
+}
+
+func TestPreflightRequestWildcard(t *testing.T) {
+	testcases := []struct {
+		name         string
+		credentials  bool
+		allowHeaders []string
+		method       string
+		headers      string
+		status       int
+		wantMethods  string
+		wantHeaders  string
+	}{
+		{
+			name:         "non-credentialed",
+			allowHeaders: []string{"*"},
+			method:       http.MethodDelete,
+			headers:      "x-foo, x-bar",
+			status:       http.StatusNoContent,
+			wantMethods:  "*",
+			wantHeaders:  "*",
+		},
+		{
+			name:         "credentialed",
+			credentials:  true,
+			allowHeaders: []string{"*"},
+			method:       http.MethodDelete,
+			headers:      "x-foo, x-bar",
+			status:       http.StatusNoContent,
+			wantMethods:  "DELETE",
+			wantHeaders:  "x-foo, x-bar",
+		},
+		{
+			name:         "authorization is not covered",
+			allowHeaders: []string{"*"},
+			method:       http.MethodGet,
+			headers:      "x-foo, authorization",
+			status:       http.StatusNoContent,
+			wantMethods:  "",
+			wantHeaders:  "",
+		},
+		{
+			name:         "explicit authorization",
+			allowHeaders: []string{"*", "Authorization"},
+			method:       http.MethodGet,
+			headers:      "x-foo, authorization",
+			status:       http.StatusNoContent,
+			wantMethods:  "*",
+			wantHeaders:  "*, Authorization",
+		},
+		{
+			name:         "credentialed explicit authorization",
+			credentials:  true,
+			allowHeaders: []string{"*", "Authorization"},
+			method:       http.MethodGet,
+			headers:      "x-foo, authorization",
+			status:       http.StatusNoContent,
+			wantMethods:  "GET",
+			wantHeaders:  "Authorization, x-foo",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			service := newService(nil)
+			fixedOrigin := "http://localhost"
+			req, _ := http.NewRequest(http.MethodOptions, "/", nil)
+			req.Header.Set(goacors.HeaderOrigin, fixedOrigin)
+			req.Header.Set(goacors.HeaderAccessControlRequestMethod, tc.method)
+			req.Header.Set(goacors.HeaderAccessControlRequestHeaders, tc.headers)
+			rw := newTestResponseWriter()
+			ctx := newContext(service, rw, req, nil)
+
+			h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+				return service.Send(ctx, http.StatusOK, "ok")
+			}
+			testee := goacors.New(service, &goacors.Config{
+				AllowOrigins:     []string{fixedOrigin},
+				AllowMethods:     []string{"*"},
+				AllowHeaders:     tc.allowHeaders,
+				AllowCredentials: tc.credentials,
+			})(h)
+			err := testee(ctx, rw, req)
+			if err != nil {
+				t.Error("it should not return any error but ", err)
+			}
+			if rw.Status != tc.status {
+				t.Errorf("the status should be %d, got %d", tc.status, rw.Status)
+			}
+			if v := rw.Header().Get(goacors.HeaderAccessControlAllowMethods); v != tc.wantMethods {
+				t.Errorf("allow methods should be %q but %q", tc.wantMethods, v)
+			}
+			if v := rw.Header().Get(goacors.HeaderAccessControlAllowHeaders); v != tc.wantHeaders {
+				t.Errorf("allow headers should be %q but %q", tc.wantHeaders, v)
+			}
+		})
+	}
+}
+
+func TestExposeHeadersWildcard(t *testing.T) {
+	testcases := []struct {
+		name        string
+		credentials bool
+		want        string
+	}{
+		{
+			name: "non-credentialed",
+			want: "*, ETag",
+		},
+		{
+			name:        "credentialed",
+			credentials: true,
+			want:        "ETag",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			service := newService(nil)
+			fixedOrigin := "http://localhost"
+			req, _ := http.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(goacors.HeaderOrigin, fixedOrigin)
+			rw := newTestResponseWriter()
+			ctx := newContext(service, rw, req, nil)
+
+			h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+				return service.Send(ctx, http.StatusOK, "ok")
+			}
+			testee := goacors.New(service, &goacors.Config{
+				AllowOrigins:     []string{fixedOrigin},
+				AllowMethods:     []string{http.MethodGet},
+				ExposeHeaders:    []string{"*", "ETag"},
+				AllowCredentials: tc.credentials,
+			})(h)
+			err := testee(ctx, rw, req)
+			if err != nil {
+				t.Error("it should not return any error but ", err)
+			}
+			if v := rw.Header().Get(goacors.HeaderAccessControlExposeHeaders); v != tc.want {
+				t.Errorf("expose headers should be %q but %q", tc.want, v)
+			}
+		})
+	}
+}
+
+func TestOptionsRequest(t *testing.T) {
+	testcases := []struct {
+		name          string
+		origin        string
+		requestMethod string
+		passthrough   bool
+		called        bool
+		status        int
+		allowOrigin   string
+		allowMethods  string
+	}{
+		{
+			name:          "preflight",
+			origin:        "http://localhost",
+			requestMethod: http.MethodPut,
+			called:        false,
+			status:        http.StatusNoContent,
+			allowOrigin:   "http://localhost",
+			allowMethods:  "PUT",
+		},
+		{
+			name:         "without request method",
+			origin:       "http://localhost",
+			called:       true,
+			status:       http.StatusOK,
+			allowOrigin:  "http://localhost",
+			allowMethods: "",
+		},
+		{
+			name:          "without origin",
+			requestMethod: http.MethodPut,
+			called:        true,
+			status:        http.StatusOK,
+			allowOrigin:   "",
+			allowMethods:  "",
+		},
+		{
+			name:          "passthrough",
+			origin:        "http://localhost",
+			requestMethod: http.MethodPut,
+			passthrough:   true,
+			called:        true,
+			status:        http.StatusOK,
+			allowOrigin:   "http://localhost",
+			allowMethods:  "PUT",
+		},
+		{
+			name:          "passthrough rejected",
+			origin:        "http://localhost",
+			requestMethod: http.MethodDelete,
+			passthrough:   true,
+			called:        true,
+			status:        http.StatusOK,
+			allowOrigin:   "",
+			allowMethods:  "",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			service := newService(nil)
+			req, _ := http.NewRequest(http.MethodOptions, "/", nil)
+			if tc.origin != "" {
+				req.Header.Set(goacors.HeaderOrigin, tc.origin)
+			}
+			if tc.requestMethod != "" {
+				req.Header.Set(goacors.HeaderAccessControlRequestMethod, tc.requestMethod)
+			}
+			rw := newTestResponseWriter()
+			ctx := newContext(service, rw, req, nil)
+
+			called := false
+			h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+				called = true
+				return service.Send(ctx, http.StatusOK, "ok")
+			}
+			testee := goacors.New(service, &goacors.Config{
+				AllowOrigins:       []string{"http://localhost"},
+				AllowMethods:       []string{http.MethodPut},
+				OptionsPassthrough: tc.passthrough,
+			})(h)
+			err := testee(ctx, rw, req)
+			if err != nil {
+				t.Error("it should not return any error but ", err)
+			}
+			if called != tc.called {
+				t.Errorf("handler called should be %v but %v", tc.called, called)
+			}
+			if rw.Status != tc.status {
+				t.Errorf("the status should be %d, got %d", tc.status, rw.Status)
+			}
+			if v := rw.Header().Get(goacors.HeaderAccessControlAllowOrigin); v != tc.allowOrigin {
+				t.Errorf("allow origin should be %q but %q", tc.allowOrigin, v)
+			}
+			if v := rw.Header().Get(goacors.HeaderAccessControlAllowMethods); v != tc.allowMethods {
+				t.Errorf("allow methods should be %q but %q", tc.allowMethods, v)
+			}
+		})
+	}
+}
+
+func TestPreflightRequestRejected(t *testing.T) {
+	testcases := []struct {
+		name   string
+		origin string
+		method string
+		status int
+		reason goacors.RejectReason
+	}{
+		{
+			name:   "origin",
+			origin: "http://evil.com",
+			method: http.MethodPut,
+			reason: goacors.ReasonOriginNotAllowed,
+		},
+		{
+			name:   "method",
+			origin: "http://localhost",
+			method: http.MethodDelete,
+			reason: goacors.ReasonMethodNotAllowed,
+		},
+		{
+			name:   "origin and method",
+			origin: "http://evil.com",
+			method: http.MethodDelete,
+			reason: goacors.ReasonOriginNotAllowed,
+		},
+		{
+			name:   "forbidden",
+			origin: "http://evil.com",
+			method: http.MethodPut,
+			status: http.StatusForbidden,
+			reason: goacors.ReasonOriginNotAllowed,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			service := newService(nil)
+			req, _ := http.NewRequest(http.MethodOptions, "/", nil)
+			req.Header.Set(goacors.HeaderOrigin, tc.origin)
+			req.Header.Set(goacors.HeaderAccessControlRequestMethod, tc.method)
+			rw := newTestResponseWriter()
+			ctx := newContext(service, rw, req, nil)
+
+			h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+				return service.Send(ctx, http.StatusOK, "ok")
+			}
+			var reason goacors.RejectReason
+			testee := goacors.New(service, &goacors.Config{
+				AllowOrigins:          []string{"http://localhost"},
+				AllowMethods:          []string{http.MethodPut},
+				AllowHeaders:          []string{"X-Foo"},
+				AllowCredentials:      true,
+				MaxAge:                3600,
+				PreflightRejectStatus: tc.status,
+				OnReject: func(ctx context.Context, req *http.Request, d *goacors.Decision) {
+					reason = d.Reason
+				},
+			})(h)
+			err := testee(ctx, rw, req)
+			if err != nil {
+				t.Error("it should not return any error but ", err)
+			}
+
+			status := tc.status
+			if status == 0 {
+				status = http.StatusNoContent
+			}
+			if rw.Status != status {
+				t.Errorf("the status should be %d, got %d", status, rw.Status)
+			}
+			for name := range rw.Header() {
+				if strings.HasPrefix(name, "Access-Control-") {
+					t.Errorf("%s should not be sent but %q", name, rw.Header().Get(name))
+				}
+			}
+			if reason != tc.reason {
+				t.Errorf("reason should be %q but %q", tc.reason, reason)
+			}
+		})
+	}
+}
+
+func TestOrigIsNotValidWithoutAllowHeaders(t *testing.T) {
+	service := newService(nil)
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(goacors.HeaderOrigin, "http://evil.com")
+	rw := newTestResponseWriter()
+	ctx := newContext(service, rw, req, nil)
+
+	h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+		return service.Send(ctx, http.StatusOK, "ok")
+	}
+	var reason goacors.RejectReason
+	testee := goacors.New(service, &goacors.Config{
+		AllowOrigins:     []string{"http://localhost"},
+		AllowMethods:     []string{http.MethodGet},
+		AllowCredentials: true,
+		ExposeHeaders:    []string{"ETag"},
+		OnReject: func(ctx context.Context, req *http.Request, d *goacors.Decision) {
+			reason = d.Reason
+		},
+	})(h)
+	err := testee(ctx, rw, req)
+	if err != nil {
+		t.Error("it should not return any error but ", err)
+	}
+	for name := range rw.Header() {
+		if strings.HasPrefix(name, "Access-Control-") {
+			t.Errorf("%s should not be sent but %q", name, rw.Header().Get(name))
+		}
+	}
+	if reason != goacors.ReasonOriginNotAllowed {
+		t.Errorf("reason should be %q but %q", goacors.ReasonOriginNotAllowed, reason)
+	}
+}
+
+func TestSameOriginIsNotRejected(t *testing.T) {
+	logger := new(testLogger)
+	service := newService(logger)
+	req, _ := http.NewRequest(http.MethodPost, "/", nil)
+	req.Host = "api.example.com"
+	req.Header.Set(goacors.HeaderOrigin, "http://api.example.com")
+	rw := newTestResponseWriter()
+	ctx := newContext(service, rw, req, nil)
+
+	h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+		return service.Send(ctx, http.StatusOK, "ok")
+	}
+	rejected := false
+	testee := goacors.New(service, &goacors.Config{
+		AllowOrigins: []string{"http://localhost"},
+		AllowMethods: []string{http.MethodPost},
+		LogLevel:     goacors.LogLevelRejected,
+		OnReject: func(ctx context.Context, req *http.Request, d *goacors.Decision) {
+			rejected = true
+		},
+	})(h)
+	err := testee(ctx, rw, req)
+	if err != nil {
+		t.Error("it should not return any error but ", err)
+	}
+	if rejected {
+		t.Error("same-origin request should not be rejected")
+	}
+	if len(logger.InfoEntries) != 0 {
+		t.Errorf("nothing should be logged, got %#v", logger.InfoEntries)
+	}
+	if got := rw.Header().Get(goacors.HeaderAccessControlAllowOrigin); got != "" {
+		t.Errorf("allow origin should be empty but %q", got)
+	}
+}
+
+func TestOriginAllowsMatcher(t *testing.T) {
+	wildcard, err := goacors.ParsePattern("https://*.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	evil, err := goacors.ParseOrigin("https://evil.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := &goacors.Config{
+		AllowOriginMatcher: goacors.AllOf(wildcard, goacors.Not(goacors.MatchExact(evil))),
+		AllowMethods:       []string{http.MethodGet},
+	}
+
+	testcases := []struct {
+		origin  string
+		want    string
+		matched string
+	}{
+		{
+			origin:  "https://www.example.com",
+			want:    "https://www.example.com",
+			matched: "allOf(https://*.example.com, not(https://evil.example.com))",
+		},
+		{
+			origin: "https://evil.example.com",
+			want:   "",
+		},
+		{
+			origin: "https://example.com",
+			want:   "",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.origin, func(t *testing.T) {
+			service := newService(nil)
+			req, _ := http.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(goacors.HeaderOrigin, tc.origin)
+			rw := newTestResponseWriter()
+			ctx := newContext(service, rw, req, nil)
+
+			var matched string
+			h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+				if d, ok := goacors.DecisionFromContext(ctx); ok {
+					matched = d.MatchedOrigin
+				}
+				return service.Send(ctx, http.StatusOK, "ok")
+			}
+			testee := goacors.New(service, conf)(h)
+			err := testee(ctx, rw, req)
+			if err != nil {
+				t.Error("it should not return any error but ", err)
+			}
+			if v := rw.Header().Get(goacors.HeaderAccessControlAllowOrigin); v != tc.want {
+				t.Errorf("allow origin should be %q but %q", tc.want, v)
+			}
+			if matched != tc.matched {
+				t.Errorf("matched origin should be %q but %q", tc.matched, matched)
+			}
+		})
+	}
+}
+
+func TestDenyOrigins(t *testing.T) {
+	testcases := []struct {
+		name      string
+		method    string
+		origin    string
+		allow     []string
+		want      string
+		wantError goacors.RejectReason
+	}{
+		{
+			name:   "allowed subdomain",
+			method: http.MethodGet,
+			origin: "https://www.example.com",
+			allow:  []string{"https://*.example.com"},
+			want:   "https://www.example.com",
+		},
+		{
+			name:      "denied subdomain",
+			method:    http.MethodGet,
+			origin:    "https://evil.sites.example.com",
+			allow:     []string{"https://*.*.example.com"},
+			wantError: goacors.ReasonOriginDenied,
+		},
+		{
+			name:      "denied exact origin",
+			method:    http.MethodGet,
+			origin:    "https://sites.example.com",
+			allow:     []string{"https://*.example.com"},
+			wantError: goacors.ReasonOriginDenied,
+		},
+		{
+			name:      "denied over any origin",
+			method:    http.MethodGet,
+			origin:    "https://sites.example.com",
+			allow:     []string{"*"},
+			wantError: goacors.ReasonOriginDenied,
+		},
+		{
+			name:      "denied preflight",
+			method:    http.MethodOptions,
+			origin:    "https://evil.sites.example.com",
+			allow:     []string{"https://*.*.example.com"},
+			wantError: goacors.ReasonOriginDenied,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			service := newService(nil)
+			req, _ := http.NewRequest(tc.method, "/", nil)
+			req.Header.Set(goacors.HeaderOrigin, tc.origin)
+			req.Header.Set(goacors.HeaderAccessControlRequestMethod, http.MethodGet)
+			rw := newTestResponseWriter()
+			ctx := newContext(service, rw, req, nil)
+
+			h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+				return service.Send(ctx, http.StatusOK, "ok")
+			}
+			var reason goacors.RejectReason
+			testee := goacors.New(service, &goacors.Config{
+				AllowOrigins: tc.allow,
+				DenyOrigins:  []string{"https://sites.example.com", "https://*.sites.example.com"},
+				AllowMethods: []string{http.MethodGet},
+				OnReject: func(ctx context.Context, req *http.Request, d *goacors.Decision) {
+					reason = d.Reason
+				},
+			})(h)
+			err := testee(ctx, rw, req)
+			if err != nil {
+				t.Error("it should not return any error but ", err)
+			}
+			if v := rw.Header().Get(goacors.HeaderAccessControlAllowOrigin); v != tc.want {
+				t.Errorf("allow origin should be %q but %q", tc.want, v)
+			}
+			if reason != tc.wantError {
+				t.Errorf("reason should be %q but %q", tc.wantError, reason)
+			}
+		})
+	}
+}
+
+func TestOriginAllowsPortWildcard(t *testing.T) {
+	testcases := []struct {
+		origin string
+		want   string
+	}{
+		{origin: "http://localhost:5173", want: "http://localhost:5173"},
+		{origin: "http://127.0.0.1:3000", want: "http://127.0.0.1:3000"},
+		{origin: "http://127.0.0.1:8080", want: ""},
+		{origin: "http://example.com:5173", want: ""},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.origin, func(t *testing.T) {
+			service := newService(nil)
+			req, _ := http.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(goacors.HeaderOrigin, tc.origin)
+			rw := newTestResponseWriter()
+			ctx := newContext(service, rw, req, nil)
+
+			h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+				return service.Send(ctx, http.StatusOK, "ok")
+			}
+			testee := goacors.New(service, &goacors.Config{
+				AllowOrigins: []string{"http://localhost:*", "http://127.0.0.1:3000-3999"},
+				AllowMethods: []string{http.MethodGet},
+			})(h)
+			err := testee(ctx, rw, req)
+			if err != nil {
+				t.Error("it should not return any error but ", err)
+			}
+			if v := rw.Header().Get(goacors.HeaderAccessControlAllowOrigin); v != tc.want {
+				t.Errorf("allow origin should be %q but %q", tc.want, v)
+			}
+		})
+	}
+}
+
+func TestInvalidAllowedOrigin(t *testing.T) {
+	testcases := []struct {
+		name string
+		conf *goacors.Config
+	}{
+		{
+			name: "port wildcard on non-loopback host",
+			conf: &goacors.Config{
+				AllowOrigins: []string{"https://example.com:*"},
+			},
+		},
+		{
+			name: "port range on non-loopback host in origin policy",
+			conf: &goacors.Config{
+				OriginPolicies: []goacors.OriginPolicy{
+					{Origins: []string{"https://example.com:8000-8999"}},
+				},
+			},
+		},
+		{
+			name: "misplaced wildcard",
+			conf: &goacors.Config{
+				AllowOrigins: []string{"https://foo.*.com"},
+			},
+		},
+		{
+			name: "port wildcard with extra scheme",
+			conf: &goacors.Config{
+				AllowSchemes: []string{"capacitor"},
+				AllowOrigins: []string{"capacitor://localhost:*"},
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			service := newService(nil)
+			defer func() {
+				if recover() == nil {
+					t.Error("it should panic")
+				}
+			}()
+			goacors.New(service, tc.conf)
+		})
+	}
+}
+
+func TestOriginAllowsPortWildcardOnAnyHost(t *testing.T) {
+	service := newService(nil)
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(goacors.HeaderOrigin, "https://example.com:8443")
+	rw := newTestResponseWriter()
+	ctx := newContext(service, rw, req, nil)
+
+	h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+		return service.Send(ctx, http.StatusOK, "ok")
+	}
+	testee := goacors.New(service, &goacors.Config{
+		AllowOrigins:                []string{"https://example.com:*"},
+		AllowMethods:                []string{http.MethodGet},
+		AllowPortWildcardsOnAnyHost: true,
+	})(h)
+	err := testee(ctx, rw, req)
+	if err != nil {
+		t.Error("it should not return any error but ", err)
+	}
+	if v := rw.Header().Get(goacors.HeaderAccessControlAllowOrigin); v != "https://example.com:8443" {
+		t.Errorf("allow origin should be %q but %q", "https://example.com:8443", v)
+	}
+}
+
+func TestOriginAllowsExtraSchemes(t *testing.T) {
+	testcases := []struct {
+		origin string
+		want   string
+	}{
+		{origin: "capacitor://localhost", want: "capacitor://localhost"},
+		{origin: "ionic://localhost", want: "ionic://localhost"},
+		{origin: "chrome-extension://abcdefghijklmnop", want: "chrome-extension://abcdefghijklmnop"},
+		{origin: "chrome-extension://ponmlkjihgfedcba", want: ""},
+		{origin: "moz-extension://6f8e6d2c-0a0b-4c1d-9e2f-3a4b5c6d7e8f", want: ""},
+		{origin: "file://localhost", want: ""},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.origin, func(t *testing.T) {
+			service := newService(nil)
+			req, _ := http.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(goacors.HeaderOrigin, tc.origin)
+			rw := newTestResponseWriter()
+			ctx := newContext(service, rw, req, nil)
+
+			h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+				return service.Send(ctx, http.StatusOK, "ok")
+			}
+			testee := goacors.New(service, &goacors.Config{
+				AllowSchemes: []string{"capacitor", "ionic", "chrome-extension", "moz-extension"},
+				AllowOrigins: []string{"capacitor://localhost", "ionic://localhost", "chrome-extension://abcdefghijklmnop"},
+				AllowMethods: []string{http.MethodGet},
+			})(h)
+			err := testee(ctx, rw, req)
+			if err != nil {
+				t.Error("it should not return any error but ", err)
+			}
+			if v := rw.Header().Get(goacors.HeaderAccessControlAllowOrigin); v != tc.want {
+				t.Errorf("allow origin should be %q but %q", tc.want, v)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package goacors
+
+import (
+	"context"
+	"net/http"
+)
+
+// decisionKey is the context key of the decision.
+type decisionKey struct{}
+
+// RejectReason is the reason why a CORS request is rejected.
+type RejectReason string
+
+const (
+	// ReasonOriginNotAllowed means that the origin is not allowed.
+	ReasonOriginNotAllowed RejectReason = "origin not allowed"
+
+	// ReasonOriginDenied means that the origin matches DenyOrigins.
+	ReasonOriginDenied RejectReason = "origin denied"
+
+	// ReasonMethodNotAllowed means that the method requested by the preflight request is not allowed.
+	ReasonMethodNotAllowed RejectReason = "method not allowed"
+
+	// ReasonHeadersNotAllowed means that some headers requested by the preflight request are not allowed.
+	ReasonHeadersNotAllowed RejectReason = "headers not allowed"
+)
+
+// Decision is the result of the evaluation of a CORS request.
+type Decision struct {
+	// Origin is the value of the Origin header.
+	// It is empty if the Origin header is missing.
+	// Note that browsers also send the Origin header in same-origin requests except GET and HEAD,
+	// so a non-empty Origin does not mean the request is cross-origin.
+	Origin string
+
+	// NormalizedOrigin is the serialization of the parsed Origin header,
+	// e.g. "http://example.com" for "HTTP://EXAMPLE.COM:80".
+	// It is empty if the Origin header is missing or invalid.
+	NormalizedOrigin string
+
+	// MatchedOrigin is the entry of AllowOrigins, AllowOriginPatterns or OriginPolicies that matches the origin.
+	// It is "*" if any origin is allowed, and empty if the origin is allowed by AllowOriginFunc
+	// or is not allowed.
+	MatchedOrigin string
+
+	// Credentialed is true if the response allows the request to include credentials,
+	// i.e. the origin is allowed and AllowCredentials is true.
+	Credentialed bool
+
+	// AllowedOrigin is the value of the Access-Control-Allow-Origin header.
+	// It is empty if the origin is not allowed.
+	AllowedOrigin string
+
+	// Preflight is true if the request is a preflight request.
+	Preflight bool
+
+	// Method is the value of the Access-Control-Request-Method header.
+	Method string
+
+	// RequestHeaders is the list of the Access-Control-Request-Headers header.
+	RequestHeaders []string
+
+	// PrivateNetwork is true if the preflight request has "Access-Control-Request-Private-Network: true".
+	PrivateNetwork bool
+
+	// Reason is the reason of the rejection. It is empty if the request is allowed.
+	Reason RejectReason
+
+	// sameOrigin is true if the origin is the origin of the request itself.
+	sameOrigin bool
+
+	// policy is the policy applied to the origin.
+	policy *policy
+
+	// allowMethods is the value of the Access-Control-Allow-Methods header.
+	allowMethods string
+}
+
+// RejectFunc defines a function called when the request is rejected.
+type RejectFunc func(ctx context.Context, req *http.Request, d *Decision)
+
+// Allowed reports whether the request is allowed.
+func (d *Decision) Allowed() bool {
+	return d.Reason == ""
+}
+
+func withDecision(ctx context.Context, d *Decision) context.Context {
+	return context.WithValue(ctx, decisionKey{}, d)
+}
+
+// DecisionFromContext returns the decision made by the CORS middleware.
+// It returns false if the middleware has not evaluated the request, e.g. the request is skipped.
+// The returned Decision must not be modified.
+func DecisionFromContext(ctx context.Context) (*Decision, bool) {
+	d, ok := ctx.Value(decisionKey{}).(*Decision)
+	return d, ok
+}
@@ -0,0 +1,164 @@
+package goacors_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/shogo82148/goacors-v1"
+)
+
+func TestDecisionFromContext(t *testing.T) {
+	testcases := []struct {
+		name   string
+		origin string
+		want   goacors.Decision
+	}{
+		{
+			name:   "same origin",
+			origin: "",
+			want:   goacors.Decision{},
+		},
+		{
+			name:   "exact origin",
+			origin: "HTTP://EXAMPLE.COM",
+			want: goacors.Decision{
+				Origin:           "HTTP://EXAMPLE.COM",
+				NormalizedOrigin: "http://example.com",
+				MatchedOrigin:    "http://example.com",
+				AllowedOrigin:    "HTTP://EXAMPLE.COM",
+				Credentialed:     true,
+			},
+		},
+		{
+			name:   "wildcard origin",
+			origin: "http://foo.example.net",
+			want: goacors.Decision{
+				Origin:           "http://foo.example.net",
+				NormalizedOrigin: "http://foo.example.net",
+				MatchedOrigin:    "http://*.example.net",
+				AllowedOrigin:    "http://foo.example.net",
+				Credentialed:     true,
+			},
+		},
+		{
+			name:   "pattern",
+			origin: "https://pr-1.preview.example.org:8443",
+			want: goacors.Decision{
+				Origin:           "https://pr-1.preview.example.org:8443",
+				NormalizedOrigin: "https://pr-1.preview.example.org:8443",
+				MatchedOrigin:    `https://pr-[0-9]+\.preview\.example\.org(:[0-9]+)?`,
+				AllowedOrigin:    "https://pr-1.preview.example.org:8443",
+				Credentialed:     true,
+			},
+		},
+		{
+			name:   "third-party origin",
+			origin: "https://evil.com",
+			want: goacors.Decision{
+				Origin:           "https://evil.com",
+				NormalizedOrigin: "https://evil.com",
+				Reason:           goacors.ReasonOriginNotAllowed,
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			service := newService(nil)
+			req, _ := http.NewRequest(http.MethodPost, "/", nil)
+			if tc.origin != "" {
+				req.Header.Set(goacors.HeaderOrigin, tc.origin)
+			}
+			rw := newTestResponseWriter()
+			ctx := newContext(service, rw, req, nil)
+
+			var got *goacors.Decision
+			h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+				d, ok := goacors.DecisionFromContext(ctx)
+				if !ok {
+					t.Fatal("decision should be found")
+				}
+				got = d
+				return service.Send(ctx, http.StatusOK, "ok")
+			}
+			testee := goacors.New(service, &goacors.Config{
+				AllowOrigins:        []string{"http://example.com", "http://*.example.net"},
+				AllowOriginPatterns: []string{`https://pr-[0-9]+\.preview\.example\.org(:[0-9]+)?`},
+				AllowCredentials:    true,
+			})(h)
+			err := testee(ctx, rw, req)
+			if err != nil {
+				t.Fatal("it should not return any error but ", err)
+			}
+
+			if got.Origin != tc.want.Origin {
+				t.Errorf("origin should be %q but %q", tc.want.Origin, got.Origin)
+			}
+			if got.NormalizedOrigin != tc.want.NormalizedOrigin {
+				t.Errorf("normalized origin should be %q but %q", tc.want.NormalizedOrigin, got.NormalizedOrigin)
+			}
+			if got.MatchedOrigin != tc.want.MatchedOrigin {
+				t.Errorf("matched origin should be %q but %q", tc.want.MatchedOrigin, got.MatchedOrigin)
+			}
+			if got.AllowedOrigin != tc.want.AllowedOrigin {
+				t.Errorf("allowed origin should be %q but %q", tc.want.AllowedOrigin, got.AllowedOrigin)
+			}
+			if got.Credentialed != tc.want.Credentialed {
+				t.Errorf("credentialed should be %v but %v", tc.want.Credentialed, got.Credentialed)
+			}
+			if got.Preflight {
+				t.Error("preflight should be false")
+			}
+			if got.Reason != tc.want.Reason {
+				t.Errorf("reason should be %q but %q", tc.want.Reason, got.Reason)
+			}
+		})
+	}
+}
+
+func TestDecisionFromContextSkipped(t *testing.T) {
+	service := newService(nil)
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(goacors.HeaderOrigin, "http://example.com")
+	rw := newTestResponseWriter()
+	ctx := newContext(service, rw, req, nil)
+
+	h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+		if _, ok := goacors.DecisionFromContext(ctx); ok {
+			t.Error("decision should not be found")
+		}
+		return service.Send(ctx, http.StatusOK, "ok")
+	}
+	testee := goacors.New(service, &goacors.Config{
+		Skipper: func(c context.Context, rw http.ResponseWriter, req *http.Request) bool {
+			return true
+		},
+		AllowOrigins: []string{"http://example.com"},
+	})(h)
+	err := testee(ctx, rw, req)
+	if err != nil {
+		t.Error("it should not return any error but ", err)
+	}
+}
+
+func TestHandlerDecisionFromContext(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(goacors.HeaderOrigin, "http://example.com")
+	rw := newTestResponseWriter()
+
+	var got *goacors.Decision
+	h := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		got, _ = goacors.DecisionFromContext(req.Context())
+	})
+	goacors.NewHandler(&goacors.Config{
+		AllowOrigins: []string{"http://example.com"},
+	})(h).ServeHTTP(rw, req)
+
+	if got == nil {
+		t.Fatal("decision should be found")
+	}
+	if !got.Allowed() {
+		t.Errorf("the request should be allowed, but rejected: %s", got.Reason)
+	}
+}
@@ -0,0 +1,33 @@
+package goacors
+
+import (
+	"net/http"
+
+	"github.com/shogo82148/goa-v1"
+)
+
+// NewHandler creates net/http middleware with configure for this.
+// It behaves in the same way as the middleware created by New, so one Config can be shared
+// between goa services and plain net/http handlers.
+// The context passed to Skipper and AllowOriginFunc is the context of the request.
+// The decisions are logged only if a goa logger is attached to the context of the request.
+func NewHandler(conf *Config) func(http.Handler) http.Handler {
+	c := newCORS(nil, conf)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			ctx, done, err := c.handle(req.Context(), rw, req)
+			if err != nil {
+				status := http.StatusInternalServerError
+				if err, ok := err.(goa.ServiceError); ok {
+					status = err.ResponseStatus()
+				}
+				http.Error(rw, http.StatusText(status), status)
+				return
+			}
+			if done {
+				return
+			}
+			next.ServeHTTP(rw, req.WithContext(ctx))
+		})
+	}
+}
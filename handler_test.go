@@ -0,0 +1,182 @@
+package goacors_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shogo82148/goa-v1"
+	"github.com/shogo82148/goacors-v1"
+)
+
+var okHandler = http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+	rw.WriteHeader(http.StatusOK)
+	rw.Write([]byte("ok"))
+})
+
+func TestHandlerRequestWithOrigin(t *testing.T) {
+	fixedOrigin := "http://localhost"
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(goacors.HeaderOrigin, fixedOrigin)
+	rw := httptest.NewRecorder()
+
+	testee := goacors.NewHandler(&goacors.Config{
+		AllowOrigins:     []string{fixedOrigin},
+		ExposeHeaders:    []string{"ETag"},
+		AllowCredentials: true,
+	})(okHandler)
+	testee.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Errorf("the status should be %d, got %d", http.StatusOK, rw.Code)
+	}
+	if rw.Header().Get(goacors.HeaderAccessControlAllowOrigin) != fixedOrigin {
+		t.Errorf("allow origin should be %s but %s", fixedOrigin, rw.Header().Get(goacors.HeaderAccessControlAllowOrigin))
+	}
+	if rw.Header().Get(goacors.HeaderAccessControlAllowCredentials) != "true" {
+		t.Error("allow credentials should be true")
+	}
+	if rw.Header().Get(goacors.HeaderAccessControlExposeHeaders) != "ETag" {
+		t.Error("expose header is unexpected ", rw.Header().Get(goacors.HeaderAccessControlExposeHeaders))
+	}
+	if rw.Header().Get(goacors.HeaderVary) != goacors.HeaderOrigin {
+		t.Error("vary header is unexpected ", rw.Header().Get(goacors.HeaderVary))
+	}
+}
+
+func TestHandlerOrigIsNotValid(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(goacors.HeaderOrigin, "http://someorigin.com")
+	rw := httptest.NewRecorder()
+
+	testee := goacors.NewHandler(&goacors.Config{
+		AllowOrigins: []string{"http://example.com"},
+	})(okHandler)
+	testee.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Errorf("the status should be %d, got %d", http.StatusOK, rw.Code)
+	}
+	if rw.Header().Get(goacors.HeaderAccessControlAllowOrigin) != "" {
+		t.Error("allow origin should be empty but ", rw.Header().Get(goacors.HeaderAccessControlAllowOrigin))
+	}
+}
+
+func TestHandlerPreflightRequest(t *testing.T) {
+	fixedOrigin := "http://localhost"
+	req := httptest.NewRequest(http.MethodOptions, "/", nil)
+	req.Header.Set(goacors.HeaderOrigin, fixedOrigin)
+	req.Header.Set(goacors.HeaderAccessControlRequestMethod, http.MethodPut)
+	req.Header.Set(goacors.HeaderAccessControlRequestHeaders, "x-foo")
+	rw := httptest.NewRecorder()
+
+	testee := goacors.NewHandler(&goacors.Config{
+		AllowOrigins: []string{fixedOrigin},
+		AllowMethods: []string{http.MethodGet, http.MethodPut},
+		AllowHeaders: []string{"X-Foo"},
+		MaxAge:       3600,
+	})(okHandler)
+	testee.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusNoContent {
+		t.Errorf("the status should be %d, got %d", http.StatusNoContent, rw.Code)
+	}
+	if rw.Body.Len() != 0 {
+		t.Errorf("the length of the body should be 0, got %d", rw.Body.Len())
+	}
+	if rw.Header().Get(goacors.HeaderAccessControlAllowOrigin) != fixedOrigin {
+		t.Errorf("allow origin should be %s but %s", fixedOrigin, rw.Header().Get(goacors.HeaderAccessControlAllowOrigin))
+	}
+	if rw.Header().Get(goacors.HeaderAccessControlAllowMethods) != "GET, PUT" {
+		t.Errorf("allow method should be %q but %q", "GET, PUT", rw.Header().Get(goacors.HeaderAccessControlAllowMethods))
+	}
+	if rw.Header().Get(goacors.HeaderAccessControlAllowHeaders) != "X-Foo" {
+		t.Errorf("allow headers should be %q but %q", "X-Foo", rw.Header().Get(goacors.HeaderAccessControlAllowHeaders))
+	}
+	if rw.Header().Get(goacors.HeaderAccessControlMaxAge) != "3600" {
+		t.Error("access control max age should be 3600 but ", rw.Header().Get(goacors.HeaderAccessControlMaxAge))
+	}
+}
+
+func TestHandlerPreflightRequestWithDisallowedMethod(t *testing.T) {
+	fixedOrigin := "http://localhost"
+	req := httptest.NewRequest(http.MethodOptions, "/", nil)
+	req.Header.Set(goacors.HeaderOrigin, fixedOrigin)
+	req.Header.Set(goacors.HeaderAccessControlRequestMethod, http.MethodDelete)
+	rw := httptest.NewRecorder()
+
+	testee := goacors.NewHandler(&goacors.Config{
+		AllowOrigins:          []string{fixedOrigin},
+		AllowMethods:          []string{http.MethodGet, http.MethodPut},
+		PreflightRejectStatus: http.StatusForbidden,
+	})(okHandler)
+	testee.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusForbidden {
+		t.Errorf("the status should be %d, got %d", http.StatusForbidden, rw.Code)
+	}
+	if rw.Header().Get(goacors.HeaderAccessControlAllowMethods) != "" {
+		t.Error("allow methods should be empty but ", rw.Header().Get(goacors.HeaderAccessControlAllowMethods))
+	}
+}
+
+func TestHandlerWithSkipper(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(goacors.HeaderOrigin, "http://example.com")
+	rw := httptest.NewRecorder()
+
+	testee := goacors.NewHandler(&goacors.Config{
+		Skipper: func(c context.Context, rw http.ResponseWriter, req *http.Request) bool {
+			return true
+		},
+		AllowOrigins: []string{"http://example.com"},
+	})(okHandler)
+	testee.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Errorf("the status should be %d, got %d", http.StatusOK, rw.Code)
+	}
+	if rw.Header().Get(goacors.HeaderAccessControlAllowOrigin) != "" {
+		t.Error("allow origin should be empty")
+	}
+}
+
+func TestHandlerOriginAllowsFuncError(t *testing.T) {
+	testcases := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{
+			name:   "plain error",
+			err:    errors.New("database is down"),
+			status: http.StatusInternalServerError,
+		},
+		{
+			name:   "service error",
+			err:    goa.NewErrorClass("service_unavailable", http.StatusServiceUnavailable)("database is down"),
+			status: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(goacors.HeaderOrigin, "http://example.com")
+			rw := httptest.NewRecorder()
+
+			testee := goacors.NewHandler(&goacors.Config{
+				AllowOriginFunc: func(ctx context.Context, origin string, req *http.Request) (bool, error) {
+					return false, tc.err
+				},
+			})(okHandler)
+			testee.ServeHTTP(rw, req)
+
+			if rw.Code != tc.status {
+				t.Errorf("the status should be %d, got %d", tc.status, rw.Code)
+			}
+		})
+	}
+}
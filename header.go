@@ -0,0 +1,49 @@
+package goacors
+
+import "strings"
+
+// parseHeaderList parses the values of a header whose value is a comma-separated list of tokens,
+// such as Access-Control-Request-Headers.
+// https://www.rfc-editor.org/rfc/rfc9110#section-5.6.1
+// > A recipient MUST accept empty list elements (e.g. ", ,") and ignore them.
+// It returns false if some elements are not valid tokens.
+func parseHeaderList(values []string) ([]string, bool) {
+	var list []string
+	for _, v := range values {
+		for _, elem := range strings.Split(v, ",") {
+			elem = strings.Trim(elem, " \t") // OWS
+			if elem == "" {
+				continue
+			}
+			if !isToken(elem) {
+				return nil, false
+			}
+			list = append(list, elem)
+		}
+	}
+	return list, true
+}
+
+// https://www.rfc-editor.org/rfc/rfc9110#section-5.6.2
+// token = 1*tchar
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isTokenChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// tchar = "!" / "#" / "$" / "%" / "&" / "'" / "*" / "+" / "-" / "." /
+// "^" / "_" / "`" / "|" / "~" / DIGIT / ALPHA
+func isTokenChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
@@ -0,0 +1,74 @@
+package goacors
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHeaderList(t *testing.T) {
+	testcases := []struct {
+		in  []string
+		out []string
+		err bool
+	}{
+		{
+			in:  nil,
+			out: nil,
+		},
+		{
+			in:  []string{"X-Foo"},
+			out: []string{"X-Foo"},
+		},
+		{
+			in:  []string{"x-foo,x-bar"},
+			out: []string{"x-foo", "x-bar"},
+		},
+
+		// optional white spaces
+		{
+			in:  []string{" x-foo ,\tx-bar\t"},
+			out: []string{"x-foo", "x-bar"},
+		},
+
+		// empty list elements are ignored
+		{
+			in:  []string{", ,x-foo,,"},
+			out: []string{"x-foo"},
+		},
+
+		// multiple header lines
+		{
+			in:  []string{"x-foo", "x-bar, x-baz"},
+			out: []string{"x-foo", "x-bar", "x-baz"},
+		},
+
+		// invalid tokens
+		{
+			in:  []string{"x foo"},
+			err: true,
+		},
+		{
+			in:  []string{"x-foo, x(bar)"},
+			err: true,
+		},
+		{
+			in:  []string{"x-foo", "\"x-bar\""},
+			err: true,
+		},
+	}
+
+	for i, tc := range testcases {
+		list, ok := parseHeaderList(tc.in)
+		if !ok {
+			if !tc.err {
+				t.Errorf("%d: want not error, got error", i)
+			}
+		} else {
+			if tc.err {
+				t.Errorf("%d: want error, got not error", i)
+			} else if !reflect.DeepEqual(list, tc.out) {
+				t.Errorf("%d: want %#v, got %#v", i, tc.out, list)
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package goacors
+
+import (
+	"context"
+
+	"github.com/shogo82148/goa-v1"
+)
+
+// LogLevel is the verbosity of the logs of CORS decisions.
+type LogLevel int
+
+const (
+	// LogLevelNone disables logging. It is the default.
+	LogLevelNone LogLevel = iota
+
+	// LogLevelRejected logs rejected origins, rejected preflight requests and errors from AllowOriginFunc.
+	LogLevelRejected
+
+	// LogLevelDebug logs allowed and skipped requests in addition to LogLevelRejected.
+	LogLevelDebug
+)
+
+// logInfo logs the message if the log level is enabled.
+// It uses the logger attached to the request context, and falls back to the service logger.
+func (c *cors) logInfo(ctx context.Context, level LogLevel, msg string, keyvals ...interface{}) {
+	if c.logLevel < level {
+		return
+	}
+	if goa.ContextLogger(ctx) != nil {
+		goa.LogInfo(ctx, msg, keyvals...)
+	} else if c.service != nil {
+		c.service.LogInfo(msg, keyvals...)
+	}
+}
+
+// logError logs the error if the log level is enabled.
+// It uses the logger attached to the request context, and falls back to the service logger.
+func (c *cors) logError(ctx context.Context, level LogLevel, msg string, keyvals ...interface{}) {
+	if c.logLevel < level {
+		return
+	}
+	if goa.ContextLogger(ctx) != nil {
+		goa.LogError(ctx, msg, keyvals...)
+	} else if c.service != nil {
+		c.service.LogError(msg, keyvals...)
+	}
+}
@@ -0,0 +1,170 @@
+package goacors_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/shogo82148/goacors-v1"
+)
+
+func findLogEntry(entries []logEntry, msg, key string, value interface{}) bool {
+	for _, e := range entries {
+		if e.Msg != msg {
+			continue
+		}
+		for i := 0; i+1 < len(e.Data); i += 2 {
+			if e.Data[i] == key && e.Data[i+1] == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestLogRejectedOrigin(t *testing.T) {
+	logger := new(testLogger)
+	service := newService(logger)
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(goacors.HeaderOrigin, "http://someorigin.com")
+	rw := newTestResponseWriter()
+	ctx := newContext(service, rw, req, nil)
+
+	h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+		return service.Send(ctx, http.StatusOK, "ok")
+	}
+	testee := goacors.New(service, &goacors.Config{
+		AllowOrigins: []string{"http://example.com"},
+		LogLevel:     goacors.LogLevelRejected,
+	})(h)
+	err := testee(ctx, rw, req)
+	if err != nil {
+		t.Error("it should not return any error but ", err)
+	}
+	if !findLogEntry(logger.InfoEntries, "cors rejected", "origin", "http://someorigin.com") {
+		t.Errorf("rejected origin should be logged, got %#v", logger.InfoEntries)
+	}
+}
+
+func TestLogRejectedPreflight(t *testing.T) {
+	testcases := []struct {
+		name    string
+		method  string
+		headers string
+		reason  string
+	}{
+		{
+			name:   "method",
+			method: http.MethodDelete,
+			reason: "method not allowed",
+		},
+		{
+			name:    "headers",
+			method:  http.MethodPut,
+			headers: "x-bar",
+			reason:  "headers not allowed",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			logger := new(testLogger)
+			service := newService(logger)
+			req, _ := http.NewRequest(http.MethodOptions, "/", nil)
+			req.Header.Set(goacors.HeaderOrigin, "http://example.com")
+			req.Header.Set(goacors.HeaderAccessControlRequestMethod, tc.method)
+			if tc.headers != "" {
+				req.Header.Set(goacors.HeaderAccessControlRequestHeaders, tc.headers)
+			}
+			rw := newTestResponseWriter()
+			ctx := newContext(service, rw, req, nil)
+
+			h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+				return service.Send(ctx, http.StatusOK, "ok")
+			}
+			testee := goacors.New(service, &goacors.Config{
+				AllowOrigins: []string{"http://example.com"},
+				AllowMethods: []string{http.MethodPut},
+				AllowHeaders: []string{"X-Foo"},
+				LogLevel:     goacors.LogLevelRejected,
+			})(h)
+			err := testee(ctx, rw, req)
+			if err != nil {
+				t.Error("it should not return any error but ", err)
+			}
+			if !findLogEntry(logger.InfoEntries, "cors preflight rejected", "reason", tc.reason) {
+				t.Errorf("rejected preflight should be logged, got %#v", logger.InfoEntries)
+			}
+		})
+	}
+}
+
+func TestLogSkipped(t *testing.T) {
+	testcases := []struct {
+		level  goacors.LogLevel
+		logged bool
+	}{
+		{
+			level:  goacors.LogLevelNone,
+			logged: false,
+		},
+		{
+			level:  goacors.LogLevelRejected,
+			logged: false,
+		},
+		{
+			level:  goacors.LogLevelDebug,
+			logged: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		logger := new(testLogger)
+		service := newService(logger)
+		req, _ := http.NewRequest(http.MethodGet, "/skipped", nil)
+		req.Header.Set(goacors.HeaderOrigin, "http://someorigin.com")
+		rw := newTestResponseWriter()
+		ctx := newContext(service, rw, req, nil)
+
+		h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+			return service.Send(ctx, http.StatusOK, "ok")
+		}
+		testee := goacors.New(service, &goacors.Config{
+			Skipper: func(c context.Context, rw http.ResponseWriter, req *http.Request) bool {
+				return true
+			},
+			AllowOrigins: []string{"http://example.com"},
+			LogLevel:     tc.level,
+		})(h)
+		err := testee(ctx, rw, req)
+		if err != nil {
+			t.Error("it should not return any error but ", err)
+		}
+		if got := findLogEntry(logger.InfoEntries, "cors skipped", "path", "/skipped"); got != tc.logged {
+			t.Errorf("level %d: want logged %v, got %v", tc.level, tc.logged, got)
+		}
+	}
+}
+
+func TestLogNone(t *testing.T) {
+	logger := new(testLogger)
+	service := newService(logger)
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(goacors.HeaderOrigin, "http://someorigin.com")
+	rw := newTestResponseWriter()
+	ctx := newContext(service, rw, req, nil)
+
+	h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+		return service.Send(ctx, http.StatusOK, "ok")
+	}
+	testee := goacors.New(service, &goacors.Config{
+		AllowOrigins: []string{"http://example.com"},
+	})(h)
+	err := testee(ctx, rw, req)
+	if err != nil {
+		t.Error("it should not return any error but ", err)
+	}
+	if len(logger.InfoEntries) != 0 || len(logger.ErrorEntries) != 0 {
+		t.Errorf("nothing should be logged, got %#v %#v", logger.InfoEntries, logger.ErrorEntries)
+	}
+}
@@ -2,83 +2,529 @@ package goacors
 
 import (
 	"fmt"
+	"net"
 	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
 )
 
+// Origin is the origin of a web content.
+// Origins are comparable, so they can be compared with == or used as map keys.
+//
 // https://developer.mozilla.org/en-US/docs/Glossary/Origin
 // > Web content's origin is defined by the scheme (protocol), host (domain), and port of the URL used to access it.
-type originType struct {
-	scheme string
-	host   string
-	port   int
+type Origin struct {
+	// Scheme is the lowercase scheme, e.g. "https".
+	Scheme string
+
+	// Host is the lowercase host name without brackets, e.g. "example.com" or "::1".
+	Host string
+
+	// Port is the port number. It is the default port of the scheme if the port is omitted.
+	// The schemes other than http and https have no default port, so it is 0 for them.
+	Port int
+}
+
+// ParseOrigin parses the origin such as "https://example.com:8443".
+// The scheme and the host are case insensitive, and they are converted to lowercase.
+// Only http and https are accepted by default. extraSchemes adds the schemes of
+// hybrid apps and browser extensions, e.g. "capacitor" or "chrome-extension".
+// The origin must not have userinfo, path, query or fragment, except for a trailing slash.
+func ParseOrigin(s string, extraSchemes ...string) (Origin, error) {
+	o, err := parseOrigin(s, extraSchemes)
+	if err != nil {
+		return Origin{}, err
+	}
+	if strings.Contains(o.Host, "*") {
+		return Origin{}, fmt.Errorf("goacors: wildcard is not allowed in origin: %s", s)
+	}
+	return o, nil
+}
+
+// String returns the serialization of the origin.
+// The default port of the scheme is omitted.
+func (o Origin) String() string {
+	if o.Port == 0 || (o.Scheme == "http" && o.Port == 80) || (o.Scheme == "https" && o.Port == 443) {
+		return o.Scheme + "://" + o.hostString()
+	}
+	return o.Scheme + "://" + o.hostString() + ":" + strconv.Itoa(o.Port)
 }
 
-func parseOrigin(s string) (originType, error) {
-	var origin originType
+func (o Origin) hostString() string {
+	if strings.Contains(o.Host, ":") {
+		// IPv6 address
+		return "[" + o.Host + "]"
+	}
+	return o.Host
+}
+
+// Equal reports whether o and other are the same origin.
+func (o Origin) Equal(other Origin) bool {
+	return o == other
+}
+
+// parseOrigin parses the origin. Unlike ParseOrigin, it accepts wildcards in the host.
+func parseOrigin(s string, extraSchemes []string) (Origin, error) {
+	var origin Origin
 	u, err := url.Parse(s)
 	if err != nil {
-		return originType{}, err
+		return Origin{}, err
 	}
 
 	switch u.Scheme {
 	case "http":
-		origin.scheme = "http"
-		origin.port = 80 // default port for http
+		origin.Scheme = "http"
+		origin.Port = 80 // default port for http
 	case "https":
-		origin.scheme = "https"
-		origin.port = 443 // default port for https
+		origin.Scheme = "https"
+		origin.Port = 443 // default port for https
 	case "":
-		return originType{}, fmt.Errorf("goacors: scheme is required: %s", s)
+		return Origin{}, fmt.Errorf("goacors: scheme is required: %s", s)
 	default:
-		// only support http and https
-		return originType{}, fmt.Errorf("goacors: unknown scheme: %s", u.Scheme)
+		if !isExtraScheme(u.Scheme, extraSchemes) {
+			return Origin{}, fmt.Errorf("goacors: unknown scheme: %s", u.Scheme)
+		}
+		// the other schemes have no default port
+		origin.Scheme = u.Scheme
+	}
+
+	// https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Origin
+	// > Origin: <scheme>://<hostname>:<port>
+	if u.User != nil || u.Opaque != "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.ForceQuery || u.Fragment != "" {
+		return Origin{}, fmt.Errorf("goacors: origin must consist of scheme, host and port: %s", s)
+	}
+	if u.Hostname() == "" {
+		return Origin{}, fmt.Errorf("goacors: host is required: %s", s)
 	}
 
 	// host is case insensitive
-	origin.host = strings.ToLower(u.Hostname())
+	origin.Host = strings.ToLower(u.Hostname())
 
 	if port := u.Port(); port != "" {
 		num, err := strconv.Atoi(port)
 		if err != nil {
-			return originType{}, err
+			return Origin{}, err
+		}
+		if num <= 0 || num > 65535 {
+			return Origin{}, fmt.Errorf("goacors: port number out of range: %s", port)
 		}
-		origin.port = num
+		origin.Port = num
 	}
 
 	return origin, nil
 }
 
-func match(origin, allowed originType) bool {
-	if origin.scheme != allowed.scheme {
+// isExtraScheme reports whether the scheme is one of extraSchemes.
+// Schemes are case insensitive.
+func isExtraScheme(scheme string, extraSchemes []string) bool {
+	for _, s := range extraSchemes {
+		if strings.EqualFold(s, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// Pattern is an origin that may contain wildcard domains, e.g. "https://*.example.com",
+// and a port wildcard or a port range, e.g. "http://localhost:*" or "http://127.0.0.1:3000-3999".
+// Each wildcard domain matches exactly one label of the host. It has the same syntax as AllowOrigins.
+type Pattern struct {
+	origin  Origin
+	minPort int
+	maxPort int
+}
+
+// ParsePattern parses the pattern of origins.
+// The wildcards must be the leading labels of the host.
+// extraSchemes has the same meaning as ParseOrigin.
+func ParsePattern(s string, extraSchemes ...string) (Pattern, error) {
+	p, err := parsePattern(s, extraSchemes)
+	if err != nil {
+		return Pattern{}, err
+	}
+	if strings.Contains(p.origin.Host, "*") {
+		if err := validateWildcard(p.origin.Host); err != nil {
+			return Pattern{}, err
+		}
+	}
+	return p, nil
+}
+
+// parsePattern parses the pattern of origins without validating the wildcard domains.
+func parsePattern(s string, extraSchemes []string) (Pattern, error) {
+	base, minPort, maxPort, ok, err := splitPortPattern(s)
+	if err != nil {
+		return Pattern{}, err
+	}
+	o, err := parseOrigin(base, extraSchemes)
+	if err != nil {
+		return Pattern{}, err
+	}
+	if !ok {
+		minPort, maxPort = o.Port, o.Port
+	} else if o.Scheme != "http" && o.Scheme != "https" {
+		// the origins of the extra schemes usually have no port, so the port wildcard never matches.
+		return Pattern{}, fmt.Errorf("goacors: port wildcards are not allowed for scheme %s: %s", o.Scheme, s)
+	}
+	o.Port = minPort
+	return Pattern{origin: o, minPort: minPort, maxPort: maxPort}, nil
+}
+
+// splitPortPattern removes the port wildcard or the port range from the pattern.
+// It returns the pattern without the port, and the range of the ports.
+// ok is false if the pattern has neither a port wildcard nor a port range.
+func splitPortPattern(s string) (base string, minPort, maxPort int, ok bool, err error) {
+	idx := strings.Index(s, "://")
+	if idx < 0 {
+		return s, 0, 0, false, nil
+	}
+	rest := s[idx+len("://"):]
+	end := strings.IndexAny(rest, "/?#")
+	if end < 0 {
+		end = len(rest)
+	}
+	authority := rest[:end]
+	colon := strings.LastIndex(authority, ":")
+	if colon < 0 || strings.Contains(authority[colon:], "]") {
+		// no port, or a part of an IPv6 address
+		return s, 0, 0, false, nil
+	}
+
+	port := authority[colon+1:]
+	if port == "*" {
+		minPort, maxPort = 1, 65535
+	} else if i := strings.Index(port, "-"); i >= 0 {
+		var err1, err2 error
+		minPort, err1 = strconv.Atoi(port[:i])
+		maxPort, err2 = strconv.Atoi(port[i+1:])
+		if err1 != nil || err2 != nil || minPort <= 0 || maxPort > 65535 || minPort > maxPort {
+			return "", 0, 0, false, fmt.Errorf("goacors: invalid port range: %s", port)
+		}
+	} else {
+		return s, 0, 0, false, nil
+	}
+	return s[:idx+len("://")] + authority[:colon] + rest[end:], minPort, maxPort, true, nil
+}
+
+// Match reports whether the origin matches the pattern.
+func (p Pattern) Match(o Origin) bool {
+	if o.Port < p.minPort || o.Port > p.maxPort {
 		return false
 	}
-	if origin.port != allowed.port {
+	return matchHost(o, p.origin)
+}
+
+// String returns the serialization of the pattern.
+func (p Pattern) String() string {
+	switch {
+	case p.minPort == p.maxPort:
+		return p.origin.String()
+	case p.minPort == 1 && p.maxPort == 65535:
+		return p.origin.Scheme + "://" + p.origin.hostString() + ":*"
+	default:
+		return p.origin.Scheme + "://" + p.origin.hostString() + ":" + strconv.Itoa(p.minPort) + "-" + strconv.Itoa(p.maxPort)
+	}
+}
+
+// hasPortRange reports whether the pattern matches several ports.
+func (p Pattern) hasPortRange() bool {
+	return p.minPort != p.maxPort
+}
+
+// isLoopback reports whether the host of the pattern is a loopback address.
+func (p Pattern) isLoopback() bool {
+	host := p.origin.Host
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// validateWildcard checks that wildcards appear only as the leading labels of the host.
+func validateWildcard(host string) error {
+	if host == "*" {
+		return fmt.Errorf("goacors: wildcard must be followed by a domain: %s", host)
+	}
+	h := host
+	for strings.HasPrefix(h, "*.") {
+		h = h[len("*."):]
+	}
+	if h == "" || strings.Contains(h, "*") {
+		return fmt.Errorf("goacors: wildcard must be the leading labels of the host: %s", host)
+	}
+	return nil
+}
+
+// matchHost reports whether the scheme and the host of the origin match the allowed one.
+// The ports are not compared.
+func matchHost(origin, allowed Origin) bool {
+	if origin.Scheme != allowed.Scheme {
 		return false
 	}
 
 	// handle wildcard domain
-	for strings.HasPrefix(allowed.host, "*.") {
-		idx := strings.Index(origin.host, ".")
+	for strings.HasPrefix(allowed.Host, "*.") {
+		idx := strings.Index(origin.Host, ".")
 		if idx <= 0 {
 			return false
 		}
-		origin.host = origin.host[idx+1:]
-		allowed.host = allowed.host[len("*."):]
+		origin.Host = origin.Host[idx+1:]
+		allowed.Host = allowed.Host[len("*."):]
 	}
-	return origin.host == allowed.host
+	return origin.Host == allowed.Host
+}
+
+// compileOriginPattern compiles the regular expression that matches the whole origin.
+func compileOriginPattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
+
+// OriginMatcher decides whether the origin matches.
+// The implementations should implement fmt.Stringer to describe themselves in logs and decisions.
+type OriginMatcher interface {
+	Match(o Origin) bool
+}
+
+// MatchExact returns an OriginMatcher that matches the origin exactly.
+func MatchExact(origin Origin) OriginMatcher {
+	return exactMatcher(origin)
+}
+
+type exactMatcher Origin
+
+func (m exactMatcher) Match(o Origin) bool {
+	return Origin(m) == o
+}
+
+func (m exactMatcher) String() string {
+	return Origin(m).String()
 }
 
-func allowed(origin string, allowedOrigins []originType, allowCredentials bool) bool {
-	o, err := parseOrigin(origin)
+// MatchRegexp returns an OriginMatcher that matches the serialization of the origin with the regular expression.
+// The pattern is anchored in the same way as AllowOriginPatterns.
+func MatchRegexp(pattern string) (OriginMatcher, error) {
+	re, err := compileOriginPattern(pattern)
 	if err != nil {
-		return false
+		return nil, err
 	}
-	for _, allowedOrigin := range allowedOrigins {
-		if match(o, allowedOrigin) {
+	return &regexpMatcher{source: pattern, re: re}, nil
+}
+
+type regexpMatcher struct {
+	source string
+	re     *regexp.Regexp
+}
+
+func (m *regexpMatcher) Match(o Origin) bool {
+	return m.re.MatchString(o.String())
+}
+
+func (m *regexpMatcher) String() string {
+	return m.source
+}
+
+// MatchFunc is an adapter to use a function as an OriginMatcher.
+type MatchFunc func(o Origin) bool
+
+// Match calls f(o).
+func (f MatchFunc) Match(o Origin) bool {
+	return f(o)
+}
+
+func (f MatchFunc) String() string {
+	return "func"
+}
+
+// MatchAny returns an OriginMatcher that matches any origin.
+func MatchAny() OriginMatcher {
+	return anyMatcher{}
+}
+
+type anyMatcher struct{}
+
+func (anyMatcher) Match(o Origin) bool {
+	return true
+}
+
+func (anyMatcher) String() string {
+	return "*"
+}
+
+// AnyOf returns an OriginMatcher that matches if any of the matchers matches.
+func AnyOf(matchers ...OriginMatcher) OriginMatcher {
+	return anyOfMatcher(matchers)
+}
+
+type anyOfMatcher []OriginMatcher
+
+func (m anyOfMatcher) Match(o Origin) bool {
+	for _, matcher := range m {
+		if matcher.Match(o) {
 			return true
 		}
 	}
 	return false
 }
+
+func (m anyOfMatcher) String() string {
+	return "anyOf(" + joinMatchers(m) + ")"
+}
+
+// AllOf returns an OriginMatcher that matches if all of the matchers match.
+// e.g. AllOf(wildcard, Not(MatchExact(evil))) matches the subdomains except evil.
+func AllOf(matchers ...OriginMatcher) OriginMatcher {
+	return allOfMatcher(matchers)
+}
+
+type allOfMatcher []OriginMatcher
+
+func (m allOfMatcher) Match(o Origin) bool {
+	for _, matcher := range m {
+		if !matcher.Match(o) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m allOfMatcher) String() string {
+	return "allOf(" + joinMatchers(m) + ")"
+}
+
+// Not returns an OriginMatcher that matches if the matcher does not match.
+func Not(matcher OriginMatcher) OriginMatcher {
+	return notMatcher{matcher: matcher}
+}
+
+type notMatcher struct {
+	matcher OriginMatcher
+}
+
+func (m notMatcher) Match(o Origin) bool {
+	return !m.matcher.Match(o)
+}
+
+func (m notMatcher) String() string {
+	return "not(" + matcherString(m.matcher) + ")"
+}
+
+// matchesEveryOrigin reports whether the matcher matches any origin, e.g. MatchAny() or AnyOf(MatchAny(), m).
+// The matchers given by users such as MatchFunc are not inspected.
+func matchesEveryOrigin(m OriginMatcher) bool {
+	switch m := m.(type) {
+	case anyMatcher:
+		return true
+	case anyOfMatcher:
+		for _, matcher := range m {
+			if matchesEveryOrigin(matcher) {
+				return true
+			}
+		}
+		return false
+	case allOfMatcher:
+		for _, matcher := range m {
+			if !matchesEveryOrigin(matcher) {
+				return false
+			}
+		}
+		return true
+	case notMatcher:
+		return matchesNoOrigin(m.matcher)
+	}
+	return false
+}
+
+// matchesNoOrigin reports whether the matcher matches no origin, e.g. Not(MatchAny()).
+func matchesNoOrigin(m OriginMatcher) bool {
+	switch m := m.(type) {
+	case anyOfMatcher:
+		for _, matcher := range m {
+			if !matchesNoOrigin(matcher) {
+				return false
+			}
+		}
+		return true
+	case allOfMatcher:
+		for _, matcher := range m {
+			if matchesNoOrigin(matcher) {
+				return true
+			}
+		}
+		return false
+	case notMatcher:
+		return matchesEveryOrigin(m.matcher)
+	}
+	return false
+}
+
+func matcherString(m OriginMatcher) string {
+	if s, ok := m.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return "matcher"
+}
+
+func joinMatchers(matchers []OriginMatcher) string {
+	list := make([]string, len(matchers))
+	for i, m := range matchers {
+		list[i] = matcherString(m)
+	}
+	return strings.Join(list, ", ")
+}
+
+// originRule is an entry of the allowed origins with the policy applied to it.
+type originRule struct {
+	// source is the entry in the configure.
+	source string
+
+	matcher OriginMatcher
+
+	// specificity is the priority of the rule.
+	// Exact origins are the most specific, wildcard domains with more labels are the next,
+	// and regular expressions and the other matchers are the least specific.
+	specificity int
+
+	policy *policy
+}
+
+func newPatternRule(source string, p Pattern, pol *policy) *originRule {
+	return &originRule{source: source, matcher: p, specificity: p.specificity(), policy: pol}
+}
+
+func newMatcherRule(m OriginMatcher, pol *policy) *originRule {
+	return &originRule{source: matcherString(m), matcher: m, policy: pol}
+}
+
+// specificity returns the priority of the pattern.
+func (p Pattern) specificity() int {
+	labels := strings.Split(p.origin.Host, ".")
+	score := 1
+	for _, label := range labels {
+		if label != "*" {
+			score++
+		}
+	}
+	if !strings.Contains(p.origin.Host, "*") && !p.hasPortRange() {
+		// exact origins win over any wildcard
+		score += 1 << 16
+	}
+	return score
+}
+
+// matchRules returns the most specific rule that matches the origin.
+// If several rules have the same specificity, the first one wins.
+// It returns nil if no rule matches.
+func matchRules(o Origin, rules []*originRule) *originRule {
+	var best *originRule
+	for _, rule := range rules {
+		if !rule.matcher.Match(o) {
+			continue
+		}
+		if best == nil || rule.specificity > best.specificity {
+			best = rule
+		}
+	}
+	return best
+}
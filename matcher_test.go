@@ -8,46 +8,64 @@ import (
 func TestParseOrigin(t *testing.T) {
 	testcases := []struct {
 		in  string
-		out originType
+		out Origin
 		err bool
 	}{
 		{
 			in: "http://example.com",
-			out: originType{
-				scheme: "http",
-				host:   "example.com",
-				port:   80,
+			out: Origin{
+				Scheme: "http",
+				Host:   "example.com",
+				Port:   80,
 			},
 		},
 		{
 			in: "https://example.com",
-			out: originType{
-				scheme: "https",
-				host:   "example.com",
-				port:   443,
+			out: Origin{
+				Scheme: "https",
+				Host:   "example.com",
+				Port:   443,
 			},
 		},
 
 		// origin is case insensitive
 		{
 			in: "HTTP://EXAMPLE.COM",
-			out: originType{
-				scheme: "http",
-				host:   "example.com",
-				port:   80,
+			out: Origin{
+				Scheme: "http",
+				Host:   "example.com",
+				Port:   80,
 			},
 		},
 
 		// set port number
 		{
 			in: "http://example.com:8080",
-			out: originType{
-				scheme: "http",
-				host:   "example.com",
-				port:   8080,
+			out: Origin{
+				Scheme: "http",
+				Host:   "example.com",
+				Port:   8080,
 			},
 		},
 
+		// IPv6 address
+		{
+			in: "http://[::1]:8080",
+			out: Origin{
+				Scheme: "http",
+				Host:   "::1",
+				Port:   8080,
+			},
+		},
+
+		{
+			in:  "http://example.com:65536",
+			err: true,
+		},
+		{
+			in:  "http://*.example.com",
+			err: true,
+		},
 		{
 			in:  "example.com",
 			err: true,
@@ -56,10 +74,52 @@ func TestParseOrigin(t *testing.T) {
 			in:  "ftp://example.com",
 			err: true,
 		},
+
+		// origin consists of scheme, host and port only
+		{
+			in: "http://example.com/",
+			out: Origin{
+				Scheme: "http",
+				Host:   "example.com",
+				Port:   80,
+			},
+		},
+		{
+			in:  "https://user@example.com",
+			err: true,
+		},
+		{
+			in:  "https://example.com/path",
+			err: true,
+		},
+		{
+			in:  "https://example.com?q",
+			err: true,
+		},
+		{
+			in:  "https://example.com#f",
+			err: true,
+		},
+		{
+			in:  "https://user@example.com/path?q#f",
+			err: true,
+		},
+		{
+			in:  "http:example.com",
+			err: true,
+		},
+		{
+			in:  "http://",
+			err: true,
+		},
+		{
+			in:  "http://:8080",
+			err: true,
+		},
 	}
 
 	for i, tc := range testcases {
-		origin, err := parseOrigin(tc.in)
+		origin, err := ParseOrigin(tc.in)
 		if err != nil {
 			if !tc.err {
 				t.Errorf("%d: want not error, got error: %v", i, err)
@@ -74,6 +134,173 @@ func TestParseOrigin(t *testing.T) {
 	}
 }
 
+func TestOriginString(t *testing.T) {
+	testcases := []struct {
+		in   string
+		want string
+	}{
+		{in: "http://example.com", want: "http://example.com"},
+		{in: "HTTPS://EXAMPLE.COM:443", want: "https://example.com"},
+		{in: "https://example.com:8443", want: "https://example.com:8443"},
+		{in: "http://example.com:443", want: "http://example.com:443"},
+		{in: "http://[::1]", want: "http://[::1]"},
+		{in: "http://[::1]:8080", want: "http://[::1]:8080"},
+	}
+
+	for i, tc := range testcases {
+		origin, err := ParseOrigin(tc.in)
+		if err != nil {
+			t.Errorf("%d: error %v", i, err)
+			continue
+		}
+		if got := origin.String(); got != tc.want {
+			t.Errorf("%d: want %q, got %q", i, tc.want, got)
+		}
+		parsed, err := ParseOrigin(origin.String())
+		if err != nil {
+			t.Errorf("%d: error %v", i, err)
+			continue
+		}
+		if !parsed.Equal(origin) {
+			t.Errorf("%d: want %+v, got %+v", i, origin, parsed)
+		}
+	}
+}
+
+func TestParseOriginExtraSchemes(t *testing.T) {
+	schemes := []string{"capacitor", "ionic", "chrome-extension", "moz-extension"}
+	testcases := []struct {
+		in  string
+		out Origin
+		str string
+		err bool
+	}{
+		{
+			in:  "capacitor://localhost",
+			out: Origin{Scheme: "capacitor", Host: "localhost"},
+			str: "capacitor://localhost",
+		},
+		{
+			in:  "IONIC://LOCALHOST",
+			out: Origin{Scheme: "ionic", Host: "localhost"},
+			str: "ionic://localhost",
+		},
+		{
+			in:  "chrome-extension://abcdefghijklmnopabcdefghijklmnop",
+			out: Origin{Scheme: "chrome-extension", Host: "abcdefghijklmnopabcdefghijklmnop"},
+			str: "chrome-extension://abcdefghijklmnopabcdefghijklmnop",
+		},
+		{
+			in:  "moz-extension://6f8e6d2c-0a0b-4c1d-9e2f-3a4b5c6d7e8f",
+			out: Origin{Scheme: "moz-extension", Host: "6f8e6d2c-0a0b-4c1d-9e2f-3a4b5c6d7e8f"},
+			str: "moz-extension://6f8e6d2c-0a0b-4c1d-9e2f-3a4b5c6d7e8f",
+		},
+		{
+			in:  "capacitor://localhost:8080",
+			out: Origin{Scheme: "capacitor", Host: "localhost", Port: 8080},
+			str: "capacitor://localhost:8080",
+		},
+		{
+			in:  "file://localhost",
+			err: true,
+		},
+	}
+
+	for i, tc := range testcases {
+		if _, err := ParseOrigin(tc.in); err == nil {
+			t.Errorf("%d: want error without extra schemes, got not error", i)
+		}
+
+		origin, err := ParseOrigin(tc.in, schemes...)
+		if err != nil {
+			if !tc.err {
+				t.Errorf("%d: want not error, got error: %v", i, err)
+			}
+			continue
+		}
+		if tc.err {
+			t.Errorf("%d: want error, got not error", i)
+			continue
+		}
+		if origin != tc.out {
+			t.Errorf("%d: want %+v, got %+v", i, tc.out, origin)
+		}
+		if got := origin.String(); got != tc.str {
+			t.Errorf("%d: want %q, got %q", i, tc.str, got)
+		}
+	}
+}
+
+func TestParsePattern(t *testing.T) {
+	testcases := []struct {
+		in   string
+		want string
+		err  bool
+	}{
+		{in: "https://*.example.com", want: "https://*.example.com"},
+		{in: "HTTPS://*.*.Example.COM:443", want: "https://*.*.example.com"},
+		{in: "https://example.com", want: "https://example.com"},
+		{in: "http://localhost:*", want: "http://localhost:*"},
+		{in: "http://127.0.0.1:3000-3999", want: "http://127.0.0.1:3000-3999"},
+		{in: "http://[::1]:*/", want: "http://[::1]:*"},
+		{in: "http://localhost:3000-3000", want: "http://localhost:3000"},
+		{in: "http://localhost:3999-3000", err: true},
+		{in: "http://localhost:1-65536", err: true},
+		{in: "http://localhost:a-b", err: true},
+		{in: "https://foo.*.example.com", err: true},
+		{in: "https://*", err: true},
+		{in: "ftp://*.example.com", err: true},
+	}
+
+	for i, tc := range testcases {
+		p, err := ParsePattern(tc.in)
+		if err != nil {
+			if !tc.err {
+				t.Errorf("%d: want not error, got error: %v", i, err)
+			}
+			continue
+		}
+		if tc.err {
+			t.Errorf("%d: want error, got not error", i)
+			continue
+		}
+		if got := p.String(); got != tc.want {
+			t.Errorf("%d: want %q, got %q", i, tc.want, got)
+		}
+	}
+}
+
+func TestParsePatternExtraSchemes(t *testing.T) {
+	schemes := []string{"capacitor", "chrome-extension"}
+	testcases := []struct {
+		in   string
+		want string
+		err  bool
+	}{
+		{in: "capacitor://localhost", want: "capacitor://localhost"},
+		{in: "chrome-extension://abcdefghijklmnop", want: "chrome-extension://abcdefghijklmnop"},
+		{in: "capacitor://localhost:*", err: true},
+		{in: "capacitor://localhost:3000-3999", err: true},
+	}
+
+	for i, tc := range testcases {
+		p, err := ParsePattern(tc.in, schemes...)
+		if err != nil {
+			if !tc.err {
+				t.Errorf("%d: want not error, got error: %v", i, err)
+			}
+			continue
+		}
+		if tc.err {
+			t.Errorf("%d: want error, got not error", i)
+			continue
+		}
+		if got := p.String(); got != tc.want {
+			t.Errorf("%d: want %q, got %q", i, tc.want, got)
+		}
+	}
+}
+
 func TestMatch(t *testing.T) {
 	testcases := []struct {
 		origin  string
@@ -117,22 +344,307 @@ func TestMatch(t *testing.T) {
 			allowed: "http://*.*.example.com",
 			want:    true,
 		},
+
+		// port wildcards and port ranges
+		{
+			origin:  "http://localhost:5173",
+			allowed: "http://localhost:*",
+			want:    true,
+		},
+		{
+			origin:  "http://localhost",
+			allowed: "http://localhost:*",
+			want:    true,
+		},
+		{
+			origin:  "https://localhost:5173",
+			allowed: "http://localhost:*",
+			want:    false,
+		},
+		{
+			origin:  "http://127.0.0.1:3000",
+			allowed: "http://127.0.0.1:3000-3999",
+			want:    true,
+		},
+		{
+			origin:  "http://127.0.0.1:4000",
+			allowed: "http://127.0.0.1:3000-3999",
+			want:    false,
+		},
+		{
+			origin:  "http://[::1]:8080",
+			allowed: "http://[::1]:*",
+			want:    true,
+		},
 	}
 
 	for i, tc := range testcases {
-		origin, err := parseOrigin(tc.origin)
+		origin, err := ParseOrigin(tc.origin)
 		if err != nil {
 			t.Errorf("%d: error %v", i, err)
 			continue
 		}
-		allowed, err := parseOrigin(tc.allowed)
+		allowed, err := ParsePattern(tc.allowed)
+		if err != nil {
+			t.Errorf("%d: error %v", i, err)
+			continue
+		}
+		got := allowed.Match(origin)
+		if got != tc.want {
+			t.Errorf("%d: want %v, got %v", i, tc.want, got)
+		}
+	}
+}
+
+func TestAllowedPattern(t *testing.T) {
+	testcases := []struct {
+		origin  string
+		pattern string
+		want    bool
+	}{
+		{
+			origin:  "https://pr-1234.preview.example.net",
+			pattern: `https://pr-[0-9]+\.preview\.example\.net`,
+			want:    true,
+		},
+		{
+			origin:  "https://feature-x--app.example.dev",
+			pattern: `https://[a-z0-9-]+--app\.example\.dev`,
+			want:    true,
+		},
+
+		// patterns are anchored
+		{
+			origin:  "https://pr-1234.preview.example.net.evil.com",
+			pattern: `https://pr-[0-9]+\.preview\.example\.net`,
+			want:    false,
+		},
+		{
+			origin:  "https://evil.com/https://pr-1234.preview.example.net",
+			pattern: `https://pr-[0-9]+\.preview\.example\.net`,
+			want:    false,
+		},
+		{
+			origin:  "https://pr-1234.preview.example.net",
+			pattern: `https://pr-1|https://pr-2`,
+			want:    false,
+		},
+
+		// invalid origin
+		{
+			origin:  "pr-1234.preview.example.net",
+			pattern: `.*pr-[0-9]+\.preview\.example\.net`,
+			want:    false,
+		},
+	}
+
+	for i, tc := range testcases {
+		m, err := MatchRegexp(tc.pattern)
 		if err != nil {
 			t.Errorf("%d: error %v", i, err)
 			continue
 		}
-		got := match(origin, allowed)
+		got := false
+		if o, err := ParseOrigin(tc.origin); err == nil {
+			got = matchRules(o, []*originRule{newMatcherRule(m, nil)}) != nil
+		}
 		if got != tc.want {
 			t.Errorf("%d: want %v, got %v", i, tc.want, got)
 		}
 	}
 }
+
+func TestMatchRules(t *testing.T) {
+	sources := []string{
+		"https://*.example.com",
+		"https://*.*.example.com",
+		"https://*.foo.example.com",
+		"https://app.example.com",
+		`https://[a-z]+\.example\.com`,
+	}
+	rules := make([]*originRule, len(sources))
+	for i, source := range sources {
+		if i == len(sources)-1 {
+			m, err := MatchRegexp(source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rules[i] = newMatcherRule(m, nil)
+			continue
+		}
+		p, err := ParsePattern(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rules[i] = newPatternRule(source, p, nil)
+	}
+
+	testcases := []struct {
+		origin string
+		want   string
+	}{
+		{
+			origin: "https://app.example.com",
+			want:   "https://app.example.com",
+		},
+		{
+			origin: "https://www.example.com",
+			want:   "https://*.example.com",
+		},
+		{
+			origin: "https://bar.foo.example.com",
+			want:   "https://*.foo.example.com",
+		},
+		{
+			origin: "https://bar.baz.example.com",
+			want:   "https://*.*.example.com",
+		},
+		{
+			origin: "https://example.com",
+			want:   "",
+		},
+	}
+
+	for i, tc := range testcases {
+		o, err := ParseOrigin(tc.origin)
+		if err != nil {
+			t.Errorf("%d: error %v", i, err)
+			continue
+		}
+		var got string
+		if rule := matchRules(o, rules); rule != nil {
+			got = rule.source
+		}
+		if got != tc.want {
+			t.Errorf("%d: want %q, got %q", i, tc.want, got)
+		}
+	}
+}
+
+func TestOriginMatcher(t *testing.T) {
+	wildcard, err := ParsePattern("https://*.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	evil, err := ParseOrigin("https://evil.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	re, err := MatchRegexp(`https://pr-[0-9]+\.example\.net`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testcases := []struct {
+		matcher OriginMatcher
+		str     string
+		origin  string
+		want    bool
+	}{
+		{
+			matcher: MatchExact(evil),
+			str:     "https://evil.example.com",
+			origin:  "HTTPS://EVIL.EXAMPLE.COM:443",
+			want:    true,
+		},
+		{
+			matcher: MatchExact(evil),
+			str:     "https://evil.example.com",
+			origin:  "https://www.example.com",
+			want:    false,
+		},
+		{
+			matcher: wildcard,
+			str:     "https://*.example.com",
+			origin:  "https://www.example.com",
+			want:    true,
+		},
+		{
+			matcher: re,
+			str:     `https://pr-[0-9]+\.example\.net`,
+			origin:  "https://pr-42.example.net",
+			want:    true,
+		},
+		{
+			matcher: re,
+			str:     `https://pr-[0-9]+\.example\.net`,
+			origin:  "https://pr-42.example.net.evil.com",
+			want:    false,
+		},
+		{
+			matcher: MatchFunc(func(o Origin) bool { return o.Port == 8080 }),
+			str:     "func",
+			origin:  "http://localhost:8080",
+			want:    true,
+		},
+		{
+			matcher: MatchAny(),
+			str:     "*",
+			origin:  "https://evil.com",
+			want:    true,
+		},
+		{
+			matcher: AllOf(wildcard, Not(MatchExact(evil))),
+			str:     "allOf(https://*.example.com, not(https://evil.example.com))",
+			origin:  "https://www.example.com",
+			want:    true,
+		},
+		{
+			matcher: AllOf(wildcard, Not(MatchExact(evil))),
+			str:     "allOf(https://*.example.com, not(https://evil.example.com))",
+			origin:  "https://evil.example.com",
+			want:    false,
+		},
+		{
+			matcher: AnyOf(MatchExact(evil), re),
+			str:     `anyOf(https://evil.example.com, https://pr-[0-9]+\.example\.net)`,
+			origin:  "https://pr-1.example.net",
+			want:    true,
+		},
+		{
+			matcher: AnyOf(),
+			str:     "anyOf()",
+			origin:  "https://www.example.com",
+			want:    false,
+		},
+	}
+
+	for i, tc := range testcases {
+		o, err := ParseOrigin(tc.origin)
+		if err != nil {
+			t.Errorf("%d: error %v", i, err)
+			continue
+		}
+		if got := tc.matcher.Match(o); got != tc.want {
+			t.Errorf("%d: want %v, got %v", i, tc.want, got)
+		}
+		if got := matcherString(tc.matcher); got != tc.str {
+			t.Errorf("%d: want %q, got %q", i, tc.str, got)
+		}
+	}
+}
+
+func TestMatchesEveryOrigin(t *testing.T) {
+	exact := MatchExact(Origin{Scheme: "https", Host: "example.com", Port: 443})
+	testcases := []struct {
+		matcher OriginMatcher
+		want    bool
+	}{
+		{matcher: nil, want: false},
+		{matcher: exact, want: false},
+		{matcher: MatchAny(), want: true},
+		{matcher: AnyOf(exact, MatchAny()), want: true},
+		{matcher: AnyOf(exact), want: false},
+		{matcher: AllOf(MatchAny(), AnyOf(MatchAny())), want: true},
+		{matcher: AllOf(MatchAny(), exact), want: false},
+		{matcher: Not(Not(MatchAny())), want: true},
+		{matcher: Not(AnyOf()), want: true},
+		{matcher: Not(AllOf(exact, Not(MatchAny()))), want: true},
+		{matcher: Not(exact), want: false},
+	}
+
+	for i, tc := range testcases {
+		if got := matchesEveryOrigin(tc.matcher); got != tc.want {
+			t.Errorf("%d: want %v, got %v", i, tc.want, got)
+		}
+	}
+}
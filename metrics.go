@@ -0,0 +1,97 @@
+package goacors
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/shogo82148/goa-v1"
+)
+
+// defaultMetricsMaxOrigins is the default value of Config.MetricsMaxOrigins.
+const defaultMetricsMaxOrigins = 100
+
+// the labels of the origins that are not used as the keys of the metrics.
+const (
+	originLabelNone    = "none"
+	originLabelInvalid = "invalid"
+	originLabelOther   = "other"
+)
+
+// originLabeler normalizes origins to use them as the keys of the metrics.
+// It limits the number of distinct origins to avoid the cardinality explosion.
+type originLabeler struct {
+	mu      sync.Mutex
+	max     int
+	schemes []string
+	seen    map[string]struct{}
+}
+
+func newOriginLabeler(max int, schemes []string) *originLabeler {
+	if max <= 0 {
+		max = defaultMetricsMaxOrigins
+	}
+	return &originLabeler{
+		max:     max,
+		schemes: schemes,
+		seen:    make(map[string]struct{}),
+	}
+}
+
+func (l *originLabeler) label(origin string) string {
+	if origin == "" {
+		return originLabelNone
+	}
+	o, err := ParseOrigin(origin, l.schemes...)
+	if err != nil {
+		return originLabelInvalid
+	}
+	label := normalizeLabel(o.String())
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.seen[label]; ok {
+		return label
+	}
+	if len(l.seen) >= l.max {
+		return originLabelOther
+	}
+	l.seen[label] = struct{}{}
+	return label
+}
+
+// normalizeLabel replaces the characters that are not safe for the metric services.
+func normalizeLabel(s string) string {
+	return strings.Map(func(r rune) rune {
+		if ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// incrDecision increments the counters of the decision.
+func (c *cors) incrDecision(d *Decision) {
+	if !c.metrics {
+		return
+	}
+	label := c.originLabels.label(d.Origin)
+
+	kind := "request"
+	if d.Preflight {
+		kind = "preflight"
+	}
+	if d.Reason == "" {
+		goa.IncrCounter([]string{"goacors", kind, "allowed", label}, 1.0)
+		return
+	}
+	goa.IncrCounter([]string{"goacors", kind, "rejected", label}, 1.0)
+	goa.IncrCounter([]string{"goacors", "rejected", strings.ReplaceAll(string(d.Reason), " ", "_"), label}, 1.0)
+}
+
+// incrSkipped increments the counter of the requests skipped by the Skipper.
+func (c *cors) incrSkipped() {
+	if !c.metrics {
+		return
+	}
+	goa.IncrCounter([]string{"goacors", "skipped"}, 1.0)
+}
@@ -0,0 +1,145 @@
+package goacors
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shogo82148/goa-v1"
+)
+
+type testCollector struct {
+	mu       sync.Mutex
+	counters map[string]float32
+}
+
+func newTestCollector() *testCollector {
+	return &testCollector{
+		counters: make(map[string]float32),
+	}
+}
+
+func (c *testCollector) AddSample(key []string, val float32)        {}
+func (c *testCollector) EmitKey(key []string, val float32)          {}
+func (c *testCollector) MeasureSince(key []string, start time.Time) {}
+func (c *testCollector) SetGauge(key []string, val float32)         {}
+
+func (c *testCollector) IncrCounter(key []string, val float32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counters[strings.Join(key, ".")] += val
+}
+
+func TestOriginLabeler(t *testing.T) {
+	l := newOriginLabeler(2, nil)
+	testcases := []struct {
+		origin string
+		want   string
+	}{
+		{
+			origin: "",
+			want:   "none",
+		},
+		{
+			origin: "example.com",
+			want:   "invalid",
+		},
+		{
+			origin: "HTTP://EXAMPLE.COM:80",
+			want:   "http___example_com",
+		},
+		{
+			origin: "https://example.com:8443",
+			want:   "https___example_com_8443",
+		},
+
+		// exceeds the limit
+		{
+			origin: "https://example.net",
+			want:   "other",
+		},
+
+		// already seen
+		{
+			origin: "http://example.com",
+			want:   "http___example_com",
+		},
+	}
+
+	for i, tc := range testcases {
+		got := l.label(tc.origin)
+		if got != tc.want {
+			t.Errorf("%d: want %s, got %s", i, tc.want, got)
+		}
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	collector := newTestCollector()
+	orig := goa.GetMetrics()
+	goa.SetMetrics(collector)
+	defer goa.SetMetrics(orig)
+
+	c := newCORS(nil, &Config{
+		Skipper: func(c context.Context, rw http.ResponseWriter, req *http.Request) bool {
+			return req.URL.Path == "/skipped"
+		},
+		AllowOrigins:  []string{"http://example.com"},
+		AllowMethods:  []string{http.MethodPut},
+		EnableMetrics: true,
+	})
+	requests := []struct {
+		method        string
+		path          string
+		origin        string
+		requestMethod string
+	}{
+		{method: http.MethodGet, path: "/", origin: "http://example.com"},
+		{method: http.MethodGet, path: "/", origin: "http://evil.com"},
+		{method: http.MethodGet, path: "/", origin: ""},
+		{method: http.MethodOptions, path: "/", origin: "http://example.com", requestMethod: http.MethodPut},
+		{method: http.MethodOptions, path: "/", origin: "http://example.com", requestMethod: http.MethodDelete},
+		{method: http.MethodGet, path: "/skipped", origin: "http://example.com"},
+	}
+	for _, r := range requests {
+		req, _ := http.NewRequest(r.method, r.path, nil)
+		if r.origin != "" {
+			req.Header.Set(HeaderOrigin, r.origin)
+		}
+		if r.requestMethod != "" {
+			req.Header.Set(HeaderAccessControlRequestMethod, r.requestMethod)
+		}
+		if _, _, err := c.handle(context.Background(), &nopResponseWriter{header: make(http.Header)}, req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := map[string]float32{
+		"goacors.request.allowed.http___example_com":             1,
+		"goacors.request.rejected.http___evil_com":               1,
+		"goacors.rejected.origin_not_allowed.http___evil_com":    1,
+		"goacors.preflight.allowed.http___example_com":           1,
+		"goacors.preflight.rejected.http___example_com":          1,
+		"goacors.rejected.method_not_allowed.http___example_com": 1,
+		"goacors.skipped": 1,
+	}
+	for key, val := range want {
+		if collector.counters[key] != val {
+			t.Errorf("%s: want %v, got %v", key, val, collector.counters[key])
+		}
+	}
+	if len(collector.counters) != len(want) {
+		t.Errorf("unexpected counters: %v", collector.counters)
+	}
+}
+
+type nopResponseWriter struct {
+	header http.Header
+}
+
+func (rw *nopResponseWriter) Header() http.Header         { return rw.header }
+func (rw *nopResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (rw *nopResponseWriter) WriteHeader(status int)      {}
@@ -0,0 +1,145 @@
+package goacors
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Policy defines the CORS settings applied to allowed origins.
+// The fields have the same meaning as the fields of Config with the same names.
+type Policy struct {
+	AllowMethods     []string
+	AllowHeaders     []string
+	AllowCredentials bool
+	ExposeHeaders    []string
+	MaxAge           int
+}
+
+// OriginPolicy attaches a Policy to specific origins.
+type OriginPolicy struct {
+	// Origins defines a list of origins that the policy is applied to.
+	// The syntax is the same as AllowOrigins, but "*" is not allowed.
+	Origins []string
+
+	// OriginPatterns defines a list of regular expressions of origins that the policy is applied to.
+	// The syntax is the same as AllowOriginPatterns.
+	OriginPatterns []string
+
+	// Matcher is an OriginMatcher that decides the origins that the policy is applied to,
+	// in addition to Origins and OriginPatterns. It is less specific than Origins.
+	Matcher OriginMatcher
+
+	// Policy is applied to the origins instead of the default policy of Config.
+	Policy
+}
+
+// policy is the compiled Policy.
+type policy struct {
+	allowMethodList  []string
+	allowMethods     string
+	allowAnyMethod   bool
+	allowHeaderList  []string
+	allowHeaders     string
+	allowHeaderSet   map[string]struct{}
+	allowAnyHeader   bool
+	allowCredentials bool
+	exposeHeaders    string
+	maxAge           string
+}
+
+func newPolicy(p Policy) *policy {
+	allowHeaderSet := make(map[string]struct{}, len(p.AllowHeaders))
+	for _, header := range p.AllowHeaders {
+		if header == "*" {
+			continue
+		}
+		// header names are case insensitive
+		allowHeaderSet[strings.ToLower(header)] = struct{}{}
+	}
+
+	exposeHeaders := p.ExposeHeaders
+	if p.AllowCredentials {
+		// https://fetch.spec.whatwg.org/#http-access-control-expose-headers
+		// the wildcard is a literal header name for credentialed requests, and it exposes nothing.
+		exposeHeaders = withoutWildcard(exposeHeaders)
+	}
+
+	var maxAge string
+	if p.MaxAge > 0 {
+		maxAge = strconv.Itoa(p.MaxAge)
+	}
+
+	return &policy{
+		allowMethodList:  p.AllowMethods,
+		allowMethods:     strings.Join(p.AllowMethods, ", "),
+		allowAnyMethod:   containsString(p.AllowMethods, "*"),
+		allowHeaderList:  p.AllowHeaders,
+		allowHeaders:     strings.Join(p.AllowHeaders, ", "),
+		allowHeaderSet:   allowHeaderSet,
+		allowAnyHeader:   containsString(p.AllowHeaders, "*"),
+		allowCredentials: p.AllowCredentials,
+		exposeHeaders:    strings.Join(exposeHeaders, ", "),
+		maxAge:           maxAge,
+	}
+}
+
+// allowedHeaders reports whether all the request headers are allowed.
+func (p *policy) allowedHeaders(headers []string) bool {
+	for _, header := range headers {
+		name := strings.ToLower(header)
+		if _, ok := p.allowHeaderSet[name]; ok {
+			continue
+		}
+
+		// https://fetch.spec.whatwg.org/#cors-non-wildcard-request-header-name
+		// the wildcard never covers Authorization, it must be listed explicitly.
+		if p.allowAnyHeader && name != "authorization" {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// expandMethods returns the value of Access-Control-Allow-Methods for credentialed requests.
+// The wildcard is a literal method name for them, so the requested method is reflected instead.
+func (p *policy) expandMethods(method string) string {
+	methods := withoutWildcard(p.allowMethodList)
+	if method != "" && !containsString(methods, method) {
+		methods = append(methods, method)
+	}
+	return strings.Join(methods, ", ")
+}
+
+// expandHeaders returns the value of Access-Control-Allow-Headers for credentialed requests.
+// The wildcard is a literal header name for them, so the requested headers are reflected instead.
+func (p *policy) expandHeaders(requestHeaders []string) string {
+	headers := withoutWildcard(p.allowHeaderList)
+	for _, header := range requestHeaders {
+		if _, ok := p.allowHeaderSet[strings.ToLower(header)]; !ok {
+			headers = append(headers, header)
+		}
+	}
+	return strings.Join(headers, ", ")
+}
+
+func withoutWildcard(list []string) []string {
+	ret := make([]string, 0, len(list))
+	for _, v := range list {
+		if v != "*" {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
+
+// defaultPolicy returns the policy defined by the fields of Config.
+func (conf *Config) defaultPolicy() Policy {
+	return Policy{
+		AllowMethods:     conf.AllowMethods,
+		AllowHeaders:     conf.AllowHeaders,
+		AllowCredentials: conf.AllowCredentials,
+		ExposeHeaders:    conf.ExposeHeaders,
+		MaxAge:           conf.MaxAge,
+	}
+}
@@ -0,0 +1,156 @@
+package goacors_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/shogo82148/goacors-v1"
+)
+
+func TestOriginPolicies(t *testing.T) {
+	conf := &goacors.Config{
+		AllowOrigins: []string{"https://*.example.com"},
+		AllowMethods: []string{http.MethodGet},
+		OriginPolicies: []goacors.OriginPolicy{
+			{
+				// the first-party SPA
+				Origins: []string{"https://app.example.com"},
+				Policy: goacors.Policy{
+					AllowMethods:     []string{http.MethodGet, http.MethodPut, http.MethodDelete},
+					AllowHeaders:     []string{"X-Csrf-Token"},
+					AllowCredentials: true,
+					MaxAge:           600,
+				},
+			},
+			{
+				// the partners
+				Origins: []string{"https://*.partner.example.org"},
+				Policy: goacors.Policy{
+					AllowMethods: []string{http.MethodGet},
+				},
+			},
+		},
+	}
+
+	testcases := []struct {
+		name        string
+		origin      string
+		method      string
+		status      int
+		allowOrigin string
+		methods     string
+		headers     string
+		credentials string
+		maxAge      string
+	}{
+		{
+			name:        "first-party",
+			origin:      "https://app.example.com",
+			method:      http.MethodDelete,
+			status:      http.StatusNoContent,
+			allowOrigin: "https://app.example.com",
+			methods:     "GET, PUT, DELETE",
+			headers:     "X-Csrf-Token",
+			credentials: "true",
+			maxAge:      "600",
+		},
+		{
+			name:        "partner",
+			origin:      "https://foo.partner.example.org",
+			method:      http.MethodGet,
+			status:      http.StatusNoContent,
+			allowOrigin: "https://foo.partner.example.org",
+			methods:     "GET",
+		},
+		{
+			name:   "partner write",
+			origin: "https://foo.partner.example.org",
+			method: http.MethodDelete,
+			status: http.StatusNoContent,
+		},
+		{
+			name:        "default",
+			origin:      "https://www.example.com",
+			method:      http.MethodGet,
+			status:      http.StatusNoContent,
+			allowOrigin: "https://www.example.com",
+			methods:     "GET",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			service := newService(nil)
+			req, _ := http.NewRequest(http.MethodOptions, "/", nil)
+			req.Header.Set(goacors.HeaderOrigin, tc.origin)
+			req.Header.Set(goacors.HeaderAccessControlRequestMethod, tc.method)
+			rw := newTestResponseWriter()
+			ctx := newContext(service, rw, req, nil)
+
+			h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+				return service.Send(ctx, http.StatusOK, "ok")
+			}
+			testee := goacors.New(service, conf)(h)
+			if err := testee(ctx, rw, req); err != nil {
+				t.Fatal("it should not return any error but ", err)
+			}
+
+			if rw.Status != tc.status {
+				t.Errorf("status should be %d but %d", tc.status, rw.Status)
+			}
+			if got := rw.Header().Get(goacors.HeaderAccessControlAllowOrigin); got != tc.allowOrigin {
+				t.Errorf("allow origin should be %q but %q", tc.allowOrigin, got)
+			}
+			if got := rw.Header().Get(goacors.HeaderAccessControlAllowMethods); got != tc.methods {
+				t.Errorf("allow methods should be %q but %q", tc.methods, got)
+			}
+			if got := rw.Header().Get(goacors.HeaderAccessControlAllowHeaders); got != tc.headers {
+				t.Errorf("allow headers should be %q but %q", tc.headers, got)
+			}
+			if got := rw.Header().Get(goacors.HeaderAccessControlAllowCredentials); got != tc.credentials {
+				t.Errorf("allow credentials should be %q but %q", tc.credentials, got)
+			}
+			if got := rw.Header().Get(goacors.HeaderAccessControlMaxAge); got != tc.maxAge {
+				t.Errorf("max age should be %q but %q", tc.maxAge, got)
+			}
+		})
+	}
+}
+
+func TestOriginPoliciesOverlapAllowOrigins(t *testing.T) {
+	service := newService(nil)
+	req, _ := http.NewRequest(http.MethodOptions, "/", nil)
+	req.Header.Set(goacors.HeaderOrigin, "https://app.example.com")
+	req.Header.Set(goacors.HeaderAccessControlRequestMethod, http.MethodDelete)
+	rw := newTestResponseWriter()
+	ctx := newContext(service, rw, req, nil)
+
+	h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+		return service.Send(ctx, http.StatusOK, "ok")
+	}
+	testee := goacors.New(service, &goacors.Config{
+		// the same entry as the origin policy
+		AllowOrigins: []string{"https://app.example.com"},
+		AllowMethods: []string{http.MethodGet},
+		OriginPolicies: []goacors.OriginPolicy{
+			{
+				Origins: []string{"https://app.example.com"},
+				Policy: goacors.Policy{
+					AllowMethods:     []string{http.MethodGet, http.MethodDelete},
+					AllowCredentials: true,
+				},
+			},
+		},
+	})(h)
+	if err := testee(ctx, rw, req); err != nil {
+		t.Fatal("it should not return any error but ", err)
+	}
+
+	if got := rw.Header().Get(goacors.HeaderAccessControlAllowMethods); got != "GET, DELETE" {
+		t.Errorf("allow methods should be %q but %q", "GET, DELETE", got)
+	}
+	if got := rw.Header().Get(goacors.HeaderAccessControlAllowCredentials); got != "true" {
+		t.Errorf("allow credentials should be %q but %q", "true", got)
+	}
+}
@@ -0,0 +1,62 @@
+package goacors
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/shogo82148/goa-v1"
+)
+
+// ErrRoutesNotRecorded is returned by MountPreflight if service.Mux is not a RouteRecorder.
+var ErrRoutesNotRecorded = errors.New("goacors: the routes are not recorded. call RecordRoutes before mounting the controllers")
+
+// MountPreflight registers OPTIONS handlers on service.Mux for all recorded routes,
+// so that preflight requests reach the CORS middleware.
+// The paths that already have OPTIONS handlers are skipped.
+//
+// service.Mux must be a RouteRecorder installed by RecordRoutes (or New with AllowMethodsFromMux)
+// before the controllers are mounted, and MountPreflight must be called after that.
+// If conf is not nil, the handlers use the CORS middleware created from conf in addition to
+// the service middleware. If conf is nil, the handlers rely on the CORS middleware added by service.Use.
+func MountPreflight(service *goa.Service, conf *Config) error {
+	r, ok := service.Mux.(*RouteRecorder)
+	if !ok {
+		return ErrRoutesNotRecorded
+	}
+
+	ctrl := service.NewController("CORS")
+	if conf != nil {
+		m, err := NewWithError(service, conf)
+		if err != nil {
+			return err
+		}
+		ctrl.Use(m)
+	}
+
+	var paths []string
+	methods := make(map[string][]string)
+	for _, route := range r.Routes() {
+		if _, ok := methods[route.Path]; !ok {
+			paths = append(paths, route.Path)
+		}
+		methods[route.Path] = append(methods[route.Path], route.Method)
+	}
+
+	for _, path := range paths {
+		if containsString(methods[path], http.MethodOptions) {
+			continue
+		}
+		allow := strings.Join(append(methods[path], http.MethodOptions), ", ")
+		h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+			// the request is not a preflight request, or the CORS middleware is skipped.
+			rw.Header().Set("Allow", allow)
+			rw.WriteHeader(http.StatusNoContent)
+			return nil
+		}
+		r.Handle(http.MethodOptions, path, ctrl.MuxHandler("preflight", h, nil))
+		goa.LogInfo(ctrl.Context, "mount", "ctrl", "CORS", "action", "preflight", "route", "OPTIONS "+path)
+	}
+	return nil
+}
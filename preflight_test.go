@@ -0,0 +1,94 @@
+package goacors_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shogo82148/goacors-v1"
+)
+
+func TestMountPreflight(t *testing.T) {
+	service := newService(nil)
+	goacors.RecordRoutes(service)
+
+	ctrl := service.NewController("bottle")
+	h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+		return service.Send(ctx, http.StatusOK, "ok")
+	}
+	service.Mux.Handle(http.MethodGet, "/bottles/:id", ctrl.MuxHandler("show", h, nil))
+	service.Mux.Handle(http.MethodPut, "/bottles/:id", ctrl.MuxHandler("update", h, nil))
+	service.Mux.Handle(http.MethodGet, "/options", ctrl.MuxHandler("show", h, nil))
+	service.Mux.Handle(http.MethodOptions, "/options", ctrl.MuxHandler("options", h, nil))
+
+	err := goacors.MountPreflight(service, &goacors.Config{
+		AllowOrigins: []string{"http://example.com"},
+		AllowMethods: []string{http.MethodGet, http.MethodPut},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// preflight requests are handled by the CORS middleware
+	req := httptest.NewRequest(http.MethodOptions, "/bottles/1", nil)
+	req.Header.Set(goacors.HeaderOrigin, "http://example.com")
+	req.Header.Set(goacors.HeaderAccessControlRequestMethod, http.MethodPut)
+	rw := httptest.NewRecorder()
+	service.Mux.ServeHTTP(rw, req)
+	if rw.Code != http.StatusNoContent {
+		t.Errorf("the status should be %d, got %d", http.StatusNoContent, rw.Code)
+	}
+	if got := rw.Header().Get(goacors.HeaderAccessControlAllowOrigin); got != "http://example.com" {
+		t.Errorf("allow origin should be %q but %q", "http://example.com", got)
+	}
+	if got := rw.Header().Get(goacors.HeaderAccessControlAllowMethods); got != "GET, PUT" {
+		t.Errorf("allow methods should be %q but %q", "GET, PUT", got)
+	}
+
+	// the existing OPTIONS handler is not replaced
+	req = httptest.NewRequest(http.MethodOptions, "/options", nil)
+	rw = httptest.NewRecorder()
+	service.Mux.ServeHTTP(rw, req)
+	if rw.Code != http.StatusOK {
+		t.Errorf("the status should be %d, got %d", http.StatusOK, rw.Code)
+	}
+
+	// calling twice is safe
+	if err := goacors.MountPreflight(service, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMountPreflightWithServiceMiddleware(t *testing.T) {
+	service := newService(nil)
+	service.Use(goacors.New(service, &goacors.Config{
+		AllowOrigins:        []string{"http://example.com"},
+		AllowMethodsFromMux: true,
+	}))
+
+	ctrl := service.NewController("bottle")
+	h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+		return service.Send(ctx, http.StatusOK, "ok")
+	}
+	service.Mux.Handle(http.MethodDelete, "/bottles/:id", ctrl.MuxHandler("delete", h, nil))
+	if err := goacors.MountPreflight(service, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodOptions, "/bottles/1", nil)
+	req.Header.Set(goacors.HeaderOrigin, "http://example.com")
+	req.Header.Set(goacors.HeaderAccessControlRequestMethod, http.MethodDelete)
+	rw := httptest.NewRecorder()
+	service.Mux.ServeHTTP(rw, req)
+	if got := rw.Header().Get(goacors.HeaderAccessControlAllowMethods); got != "DELETE" {
+		t.Errorf("allow methods should be %q but %q", "DELETE", got)
+	}
+}
+
+func TestMountPreflightWithoutRecorder(t *testing.T) {
+	service := newService(nil)
+	if err := goacors.MountPreflight(service, nil); err != goacors.ErrRoutesNotRecorded {
+		t.Errorf("want ErrRoutesNotRecorded, got %v", err)
+	}
+}
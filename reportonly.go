@@ -0,0 +1,57 @@
+package goacors
+
+import (
+	"context"
+	"net/http"
+)
+
+// DivergenceFunc defines a function called when the decision of the report-only configure
+// differs from the enforced one in Reason, AllowedOrigin or Credentialed.
+type DivergenceFunc func(ctx context.Context, req *http.Request, enforced, candidate *Decision)
+
+// compareReportOnly evaluates the request with the report-only configure,
+// and reports the divergence from the enforced decision.
+// It never changes the response.
+func (c *cors) compareReportOnly(ctx context.Context, req *http.Request, enforced *Decision) {
+	candidate, err := c.reportOnly.evaluate(ctx, req)
+	if err != nil {
+		c.logError(ctx, LogLevelRejected, "cors report-only evaluation failed", "origin", enforced.Origin, "err", err)
+		return
+	}
+	if !diverged(enforced, candidate) {
+		return
+	}
+
+	if c.onDivergence != nil {
+		c.onDivergence(ctx, req, enforced, candidate)
+		return
+	}
+	c.logInfo(
+		ctx, LogLevelRejected, "cors report-only divergence",
+		"origin", enforced.Origin,
+		"preflight", enforced.Preflight,
+		"enforced", decisionResult(enforced),
+		"candidate", decisionResult(candidate),
+		"enforced_allow_origin", enforced.AllowedOrigin,
+		"candidate_allow_origin", candidate.AllowedOrigin,
+		"enforced_credentialed", enforced.Credentialed,
+		"candidate_credentialed", candidate.Credentialed,
+	)
+}
+
+// diverged reports whether the decisions lead to different responses.
+// Both decisions may allow the request, but differ in Access-Control-Allow-Origin or credentials,
+// which breaks the credentialed requests of browsers.
+func diverged(enforced, candidate *Decision) bool {
+	return enforced.Reason != candidate.Reason ||
+		enforced.AllowedOrigin != candidate.AllowedOrigin ||
+		enforced.Credentialed != candidate.Credentialed
+}
+
+// decisionResult returns "allowed" or the reason of the rejection.
+func decisionResult(d *Decision) string {
+	if d.Allowed() {
+		return "allowed"
+	}
+	return string(d.Reason)
+}
@@ -0,0 +1,215 @@
+package goacors_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/shogo82148/goacors-v1"
+)
+
+func TestReportOnly(t *testing.T) {
+	testcases := []struct {
+		name          string
+		method        string
+		origin        string
+		requestMethod string
+		allowOrigin   string
+		enforced      goacors.RejectReason
+		candidate     goacors.RejectReason
+		diverged      bool
+	}{
+		{
+			name:        "both allowed",
+			method:      http.MethodGet,
+			origin:      "http://example.com",
+			allowOrigin: "http://example.com",
+		},
+		{
+			name:        "origin is rejected by the candidate",
+			method:      http.MethodGet,
+			origin:      "http://old.example.com",
+			allowOrigin: "http://old.example.com",
+			candidate:   goacors.ReasonOriginNotAllowed,
+			diverged:    true,
+		},
+		{
+			name:          "method is rejected by the candidate",
+			method:        http.MethodOptions,
+			origin:        "http://example.com",
+			requestMethod: http.MethodDelete,
+			allowOrigin:   "http://example.com",
+			candidate:     goacors.ReasonMethodNotAllowed,
+			diverged:      true,
+		},
+		{
+			name:        "origin is allowed by the candidate",
+			method:      http.MethodGet,
+			origin:      "http://new.example.com",
+			allowOrigin: "",
+			enforced:    goacors.ReasonOriginNotAllowed,
+			diverged:    true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			service := newService(nil)
+			req, _ := http.NewRequest(tc.method, "/", nil)
+			req.Header.Set(goacors.HeaderOrigin, tc.origin)
+			if tc.requestMethod != "" {
+				req.Header.Set(goacors.HeaderAccessControlRequestMethod, tc.requestMethod)
+			}
+			rw := newTestResponseWriter()
+			ctx := newContext(service, rw, req, nil)
+
+			h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+				return service.Send(ctx, http.StatusOK, "ok")
+			}
+			var diverged bool
+			testee := goacors.New(service, &goacors.Config{
+				AllowOrigins: []string{"http://example.com", "http://old.example.com"},
+				AllowMethods: []string{http.MethodPut, http.MethodDelete},
+				ReportOnly: &goacors.Config{
+					AllowOrigins: []string{"http://example.com", "http://new.example.com"},
+					AllowMethods: []string{http.MethodPut},
+				},
+				OnDivergence: func(ctx context.Context, req *http.Request, enforced, candidate *goacors.Decision) {
+					diverged = true
+					if enforced.Reason != tc.enforced {
+						t.Errorf("enforced reason should be %q but %q", tc.enforced, enforced.Reason)
+					}
+					if candidate.Reason != tc.candidate {
+						t.Errorf("candidate reason should be %q but %q", tc.candidate, candidate.Reason)
+					}
+				},
+			})(h)
+			err := testee(ctx, rw, req)
+			if err != nil {
+				t.Error("it should not return any error but ", err)
+			}
+			if diverged != tc.diverged {
+				t.Errorf("diverged should be %v but %v", tc.diverged, diverged)
+			}
+
+			// the response follows the enforced config
+			if got := rw.Header().Get(goacors.HeaderAccessControlAllowOrigin); got != tc.allowOrigin {
+				t.Errorf("allow origin should be %q but %q", tc.allowOrigin, got)
+			}
+		})
+	}
+}
+
+func TestReportOnlyLogDivergence(t *testing.T) {
+	logger := new(testLogger)
+	service := newService(logger)
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(goacors.HeaderOrigin, "http://old.example.com")
+	rw := newTestResponseWriter()
+	ctx := newContext(service, rw, req, nil)
+
+	h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+		return service.Send(ctx, http.StatusOK, "ok")
+	}
+	testee := goacors.New(service, &goacors.Config{
+		AllowOrigins: []string{"http://old.example.com"},
+		LogLevel:     goacors.LogLevelRejected,
+		ReportOnly: &goacors.Config{
+			AllowOrigins: []string{"http://example.com"},
+		},
+	})(h)
+	err := testee(ctx, rw, req)
+	if err != nil {
+		t.Error("it should not return any error but ", err)
+	}
+	if !findLogEntry(logger.InfoEntries, "cors report-only divergence", "candidate", "origin not allowed") {
+		t.Errorf("divergence should be logged, got %#v", logger.InfoEntries)
+	}
+	if rw.Header().Get(goacors.HeaderAccessControlAllowOrigin) != "http://old.example.com" {
+		t.Errorf("allow origin should be %q but %q", "http://old.example.com", rw.Header().Get(goacors.HeaderAccessControlAllowOrigin))
+	}
+}
+
+func TestReportOnlyLogLevelNone(t *testing.T) {
+	logger := new(testLogger)
+	service := newService(logger)
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(goacors.HeaderOrigin, "http://old.example.com")
+	rw := newTestResponseWriter()
+	ctx := newContext(service, rw, req, nil)
+
+	h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+		return service.Send(ctx, http.StatusOK, "ok")
+	}
+	testee := goacors.New(service, &goacors.Config{
+		AllowOrigins: []string{"http://old.example.com"},
+		ReportOnly: &goacors.Config{
+			AllowOrigins: []string{"http://example.com"},
+		},
+	})(h)
+	err := testee(ctx, rw, req)
+	if err != nil {
+		t.Error("it should not return any error but ", err)
+	}
+	if len(logger.InfoEntries) != 0 {
+		t.Errorf("nothing should be logged, got %#v", logger.InfoEntries)
+	}
+}
+
+func TestReportOnlyAllowedDivergence(t *testing.T) {
+	testcases := []struct {
+		name      string
+		enforced  *goacors.Config
+		candidate *goacors.Config
+	}{
+		{
+			name: "credentials are dropped",
+			enforced: &goacors.Config{
+				AllowOrigins:     []string{"http://example.com"},
+				AllowCredentials: true,
+			},
+			candidate: &goacors.Config{
+				AllowOrigins: []string{"http://example.com"},
+			},
+		},
+		{
+			name: "allow origin is changed",
+			enforced: &goacors.Config{
+				AllowOrigins: []string{"http://example.com"},
+			},
+			candidate: &goacors.Config{
+				AllowOrigins: []string{"*"},
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			service := newService(nil)
+			req, _ := http.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(goacors.HeaderOrigin, "http://example.com")
+			rw := newTestResponseWriter()
+			ctx := newContext(service, rw, req, nil)
+
+			h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+				return service.Send(ctx, http.StatusOK, "ok")
+			}
+			var diverged bool
+			conf := tc.enforced
+			conf.ReportOnly = tc.candidate
+			conf.OnDivergence = func(ctx context.Context, req *http.Request, enforced, candidate *goacors.Decision) {
+				diverged = true
+				if !enforced.Allowed() || !candidate.Allowed() {
+					t.Errorf("both decisions should be allowed, got %q and %q", enforced.Reason, candidate.Reason)
+				}
+			}
+			err := goacors.New(service, conf)(h)(ctx, rw, req)
+			if err != nil {
+				t.Error("it should not return any error but ", err)
+			}
+			if !diverged {
+				t.Error("divergence should be reported")
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package goacors
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/shogo82148/goa-v1"
+)
+
+// Route is a route registered on goa.ServeMux.
+type Route struct {
+	// Method is the HTTP method of the route.
+	Method string
+
+	// Path is the path pattern of the route, e.g. "/bottles/:id".
+	Path string
+}
+
+// RouteRecorder is a goa.ServeMux that records the routes registered on the underlying mux.
+// goa.ServeMux does not provide the way to list the routes, so RouteRecorder must be
+// installed before the controllers are mounted.
+type RouteRecorder struct {
+	goa.ServeMux
+
+	mu     sync.RWMutex
+	routes []Route
+}
+
+// RecordRoutes replaces service.Mux with a RouteRecorder that wraps the original mux, and returns it.
+// If service.Mux is already a RouteRecorder, it is returned as is.
+// It must be called before the controllers are mounted.
+func RecordRoutes(service *goa.Service) *RouteRecorder {
+	if r, ok := service.Mux.(*RouteRecorder); ok {
+		return r
+	}
+	r := &RouteRecorder{ServeMux: service.Mux}
+	service.Mux = r
+	return r
+}
+
+// Handle records the route, and sets the MuxHandler to the underlying mux.
+func (r *RouteRecorder) Handle(method, path string, handle goa.MuxHandler) {
+	r.mu.Lock()
+	r.routes = append(r.routes, Route{Method: method, Path: path})
+	r.mu.Unlock()
+	r.ServeMux.Handle(method, path, handle)
+}
+
+// Routes returns the recorded routes in the registration order.
+func (r *RouteRecorder) Routes() []Route {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	routes := make([]Route, len(r.routes))
+	copy(routes, r.routes)
+	return routes
+}
+
+// Methods returns the methods registered for the request path.
+// If several path patterns match the request path, the most specific pattern is used
+// in the same way as the default goa mux: static segments win over parameters,
+// and parameters win over catch-all wildcards.
+func (r *RouteRecorder) Methods(path string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var best string
+	var bestScore []int
+	for _, route := range r.routes {
+		score, ok := matchPath(route.Path, path)
+		if !ok {
+			continue
+		}
+		if bestScore == nil || moreSpecific(score, bestScore) {
+			best, bestScore = route.Path, score
+		}
+	}
+	if bestScore == nil {
+		return nil
+	}
+
+	var methods []string
+	seen := map[string]struct{}{}
+	for _, route := range r.routes {
+		if route.Path != best {
+			continue
+		}
+		if _, ok := seen[route.Method]; ok {
+			continue
+		}
+		seen[route.Method] = struct{}{}
+		methods = append(methods, route.Method)
+	}
+	return methods
+}
+
+// the kinds of path segments, in the order of priority.
+const (
+	segmentStatic = iota
+	segmentParam
+	segmentCatchAll
+)
+
+// matchPath matches the request path against the path pattern such as "/bottles/:id" or "/files/*filepath".
+// It returns the kinds of the segments used for choosing the most specific pattern.
+func matchPath(pattern, path string) ([]int, bool) {
+	patterns := strings.Split(strings.TrimPrefix(pattern, "/"), "/")
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	score := make([]int, 0, len(patterns))
+	for i, p := range patterns {
+		if strings.HasPrefix(p, "*") {
+			// catch-all matches the rest of the path
+			return append(score, segmentCatchAll), true
+		}
+		if i >= len(segments) {
+			return nil, false
+		}
+		if strings.HasPrefix(p, ":") {
+			if segments[i] == "" {
+				return nil, false
+			}
+			score = append(score, segmentParam)
+			continue
+		}
+		if p != segments[i] {
+			return nil, false
+		}
+		score = append(score, segmentStatic)
+	}
+	if len(patterns) != len(segments) {
+		return nil, false
+	}
+	return score, true
+}
+
+// moreSpecific reports whether the score a is more specific than b.
+func moreSpecific(a, b []int) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) > len(b)
+}
+
+// routeMethods returns the methods registered on the mux for the path.
+// The methods are restricted to AllowMethods of the policy if it is neither empty nor a wildcard.
+func (c *cors) routeMethods(path string, p *policy) []string {
+	var methods []string
+	for _, method := range c.routes.Methods(path) {
+		if method == http.MethodOptions {
+			continue
+		}
+		if len(p.allowMethodList) > 0 && !p.allowAnyMethod && !containsString(p.allowMethodList, method) {
+			continue
+		}
+		methods = append(methods, method)
+	}
+	return methods
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package goacors_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/shogo82148/goacors-v1"
+)
+
+func TestRouteRecorderMethods(t *testing.T) {
+	service := newService(nil)
+	r := goacors.RecordRoutes(service)
+	if goacors.RecordRoutes(service) != r {
+		t.Error("RecordRoutes should return the same recorder")
+	}
+
+	nop := func(rw http.ResponseWriter, req *http.Request, params url.Values) {}
+	service.Mux.Handle(http.MethodGet, "/bottles", nop)
+	service.Mux.Handle(http.MethodPost, "/bottles", nop)
+	service.Mux.Handle(http.MethodGet, "/bottles/:id", nop)
+	service.Mux.Handle(http.MethodDelete, "/bottles/:id", nop)
+	service.Mux.Handle(http.MethodPut, "/bottles/new", nop)
+	service.Mux.Handle(http.MethodGet, "/files/*filepath", nop)
+
+	testcases := []struct {
+		path string
+		want []string
+	}{
+		{
+			path: "/bottles",
+			want: []string{http.MethodGet, http.MethodPost},
+		},
+		{
+			path: "/bottles/1",
+			want: []string{http.MethodGet, http.MethodDelete},
+		},
+		{
+			// static segments win over parameters
+			path: "/bottles/new",
+			want: []string{http.MethodPut},
+		},
+		{
+			path: "/files/foo/bar.txt",
+			want: []string{http.MethodGet},
+		},
+		{
+			path: "/bottles/1/comments",
+			want: nil,
+		},
+		{
+			path: "/unknown",
+			want: nil,
+		},
+	}
+
+	for _, tc := range testcases {
+		got := r.Methods(tc.path)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: want %v, got %v", tc.path, tc.want, got)
+		}
+	}
+
+	if got := len(r.Routes()); got != 6 {
+		t.Errorf("want 6 routes, got %d", got)
+	}
+}
+
+func TestAllowMethodsFromMux(t *testing.T) {
+	service := newService(nil)
+	service.Use(goacors.New(service, &goacors.Config{
+		AllowOrigins:        []string{"http://example.com"},
+		AllowMethodsFromMux: true,
+	}))
+
+	// mount the controller after the middleware is created
+	ctrl := service.NewController("bottle")
+	h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+		return service.Send(ctx, http.StatusOK, "ok")
+	}
+	service.Mux.Handle(http.MethodGet, "/bottles/:id", ctrl.MuxHandler("show", h, nil))
+	service.Mux.Handle(http.MethodDelete, "/bottles/:id", ctrl.MuxHandler("delete", h, nil))
+	service.Mux.Handle(http.MethodOptions, "/bottles/:id", ctrl.MuxHandler("preflight", h, nil))
+	service.Mux.Handle(http.MethodGet, "/readonly/:id", ctrl.MuxHandler("show", h, nil))
+	service.Mux.Handle(http.MethodOptions, "/readonly/:id", ctrl.MuxHandler("preflight", h, nil))
+
+	testcases := []struct {
+		path         string
+		method       string
+		allowMethods string
+		allowOrigin  string
+	}{
+		{
+			path:         "/bottles/1",
+			method:       http.MethodDelete,
+			allowMethods: "GET, DELETE",
+			allowOrigin:  "http://example.com",
+		},
+		{
+			path:         "/readonly/1",
+			method:       http.MethodDelete,
+			allowMethods: "",
+		},
+		{
+			path:         "/unrouted",
+			method:       http.MethodGet,
+			allowMethods: "",
+		},
+	}
+
+	for _, tc := range testcases {
+		req := httptest.NewRequest(http.MethodOptions, tc.path, nil)
+		req.Header.Set(goacors.HeaderOrigin, "http://example.com")
+		req.Header.Set(goacors.HeaderAccessControlRequestMethod, tc.method)
+		rw := httptest.NewRecorder()
+		service.Mux.ServeHTTP(rw, req)
+
+		if got := rw.Header().Get(goacors.HeaderAccessControlAllowMethods); got != tc.allowMethods {
+			t.Errorf("%s: allow methods should be %q but %q", tc.path, tc.allowMethods, got)
+		}
+		if got := rw.Header().Get(goacors.HeaderAccessControlAllowOrigin); got != tc.allowOrigin {
+			t.Errorf("%s: allow origin should be %q but %q", tc.path, tc.allowOrigin, got)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package goacors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/shogo82148/goa-v1"
+)
+
+// RoutePolicy applies a Config to the requests of specific routes.
+// A request matches the route if it matches one of Paths, or it is handled by Controller and Actions.
+type RoutePolicy struct {
+	// Paths defines a list of path patterns in the syntax of the goa mux,
+	// e.g. "/bottles/:id" or "/public/*filepath".
+	Paths []string
+
+	// Controller is the name of the goa controller, e.g. "BottleController".
+	// Note that the preflight requests mounted by MountPreflight are handled by the "CORS" controller,
+	// so use Paths to select the Config for them.
+	Controller string
+
+	// Actions defines a list of the actions of Controller.
+	// If it is empty, all actions of Controller match.
+	Actions []string
+
+	// Config is applied to the requests of the route.
+	Config *Config
+}
+
+// routePolicy is the compiled RoutePolicy.
+type routePolicy struct {
+	paths      []string
+	controller string
+	actions    []string
+	cors       *cors
+}
+
+func (r *routePolicy) match(ctx context.Context, req *http.Request) bool {
+	for _, path := range r.paths {
+		if _, ok := matchPath(path, req.URL.Path); ok {
+			return true
+		}
+	}
+	if r.controller == "" || goa.ContextController(ctx) != r.controller {
+		return false
+	}
+	return len(r.actions) == 0 || containsString(r.actions, goa.ContextAction(ctx))
+}
+
+// NewSelector creates middleware that selects the Config for each request.
+// The routes are evaluated in order, and the Config of the first matching route is used.
+// If no route matches, conf is used.
+// It panics if the configures are invalid. Use NewSelectorWithError to validate the configures.
+func NewSelector(service *goa.Service, conf *Config, routes ...RoutePolicy) goa.Middleware {
+	def := newCORS(service, conf)
+	compiled := make([]*routePolicy, 0, len(routes))
+	for _, r := range routes {
+		compiled = append(compiled, &routePolicy{
+			paths:      r.Paths,
+			controller: r.Controller,
+			actions:    r.Actions,
+			cors:       newCORS(service, r.Config),
+		})
+	}
+
+	return func(next goa.Handler) goa.Handler {
+		return func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+			c := def
+			for _, r := range compiled {
+				if r.match(ctx, req) {
+					c = r.cors
+					break
+				}
+			}
+
+			ctx, done, err := c.handle(ctx, rw, req)
+			if err != nil {
+				return err
+			}
+			if done {
+				return nil
+			}
+			return next(ctx, rw, req)
+		}
+	}
+}
+
+// NewSelectorWithError creates middleware that selects the Config for each request.
+// Unlike NewSelector, it validates the configures and returns the error instead of panicking.
+// The fields of the routes are reported with the prefix such as "Routes[0].".
+func NewSelectorWithError(service *goa.Service, conf *Config, routes ...RoutePolicy) (goa.Middleware, error) {
+	v := &validator{}
+	if conf == nil {
+		v.add("Config", "", errors.New("missing config"))
+	} else if err := conf.Validate(); err != nil {
+		v.errs = append(v.errs, err.(ConfigErrors)...)
+	}
+	for i, r := range routes {
+		prefix := fmt.Sprintf("Routes[%d].", i)
+		if len(r.Paths) == 0 && r.Controller == "" {
+			v.add(prefix+"Paths", "", errors.New("empty route"))
+		}
+		for j, path := range r.Paths {
+			if !strings.HasPrefix(path, "/") {
+				v.add(fmt.Sprintf("%sPaths[%d]", prefix, j), path, errors.New("path must start with /"))
+			}
+		}
+		if len(r.Actions) > 0 && r.Controller == "" {
+			v.add(prefix+"Actions", "", errors.New("actions without controller"))
+		}
+		if r.Config == nil {
+			v.add(prefix+"Config", "", errors.New("missing config"))
+			continue
+		}
+		if err := r.Config.Validate(); err != nil {
+			for _, e := range err.(ConfigErrors) {
+				v.add(prefix+"Config."+e.Field, e.Value, e.Err)
+			}
+		}
+	}
+	if len(v.errs) > 0 {
+		return nil, v.errs
+	}
+	return NewSelector(service, conf, routes...), nil
+}
@@ -0,0 +1,201 @@
+package goacors_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/shogo82148/goa-v1"
+	"github.com/shogo82148/goacors-v1"
+)
+
+func TestSelector(t *testing.T) {
+	public := &goacors.Config{
+		AllowOrigins: []string{"*"},
+		AllowMethods: []string{http.MethodGet},
+	}
+	admin := &goacors.Config{
+		AllowOrigins:     []string{"https://console.example.com"},
+		AllowMethods:     []string{http.MethodGet, http.MethodPut},
+		AllowCredentials: true,
+	}
+	def := &goacors.Config{
+		AllowOrigins: []string{"https://www.example.com"},
+		AllowMethods: []string{http.MethodGet},
+	}
+	routes := []goacors.RoutePolicy{
+		{
+			Paths:  []string{"/public/*filepath"},
+			Config: public,
+		},
+		{
+			Paths:      []string{"/admin/*filepath"},
+			Controller: "AdminController",
+			Config:     admin,
+		},
+		{
+			Controller: "BottleController",
+			Actions:    []string{"list", "show"},
+			Config:     public,
+		},
+	}
+
+	testcases := []struct {
+		name        string
+		path        string
+		controller  string
+		action      string
+		origin      string
+		allowOrigin string
+	}{
+		{
+			name:        "public path",
+			path:        "/public/image.png",
+			controller:  "AssetController",
+			action:      "download",
+			origin:      "https://foo.example.net",
+			allowOrigin: "*",
+		},
+		{
+			name:        "admin path",
+			path:        "/admin/users",
+			controller:  "UserController",
+			action:      "list",
+			origin:      "https://console.example.com",
+			allowOrigin: "https://console.example.com",
+		},
+		{
+			name:        "admin path from other origin",
+			path:        "/admin/users",
+			controller:  "UserController",
+			action:      "list",
+			origin:      "https://www.example.com",
+			allowOrigin: "",
+		},
+		{
+			name:        "admin controller",
+			path:        "/settings",
+			controller:  "AdminController",
+			action:      "show",
+			origin:      "https://console.example.com",
+			allowOrigin: "https://console.example.com",
+		},
+		{
+			name:        "bottle action",
+			path:        "/bottles/1",
+			controller:  "BottleController",
+			action:      "show",
+			origin:      "https://foo.example.net",
+			allowOrigin: "*",
+		},
+		{
+			name:        "other bottle action",
+			path:        "/bottles/1",
+			controller:  "BottleController",
+			action:      "delete",
+			origin:      "https://foo.example.net",
+			allowOrigin: "",
+		},
+		{
+			name:        "default",
+			path:        "/bottles/1",
+			controller:  "BottleController",
+			action:      "delete",
+			origin:      "https://www.example.com",
+			allowOrigin: "https://www.example.com",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			service := newService(nil)
+			req, _ := http.NewRequest(http.MethodGet, tc.path, nil)
+			req.Header.Set(goacors.HeaderOrigin, tc.origin)
+			rw := newTestResponseWriter()
+			ctrl := service.NewController(tc.controller)
+			ctx := goa.NewContext(goa.WithAction(ctrl.Context, tc.action), rw, req, nil)
+
+			h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+				return service.Send(ctx, http.StatusOK, "ok")
+			}
+			testee := goacors.NewSelector(service, def, routes...)(h)
+			if err := testee(ctx, rw, req); err != nil {
+				t.Fatal("it should not return any error but ", err)
+			}
+
+			if got := rw.Header().Get(goacors.HeaderAccessControlAllowOrigin); got != tc.allowOrigin {
+				t.Errorf("allow origin should be %q but %q", tc.allowOrigin, got)
+			}
+		})
+	}
+}
+
+func TestNewSelectorWithError(t *testing.T) {
+	service := newService(nil)
+	def := &goacors.Config{
+		AllowOrigins: []string{"https://www.example.com"},
+		AllowMethods: []string{http.MethodGet},
+	}
+
+	m, err := goacors.NewSelectorWithError(service, def, goacors.RoutePolicy{
+		Paths:  []string{"/public/*filepath"},
+		Config: def,
+	})
+	if err != nil {
+		t.Errorf("want no error, got %v", err)
+	}
+	if m == nil {
+		t.Error("want middleware, got nil")
+	}
+
+	m, err = goacors.NewSelectorWithError(service, def,
+		goacors.RoutePolicy{
+			Paths: []string{"public"},
+			Config: &goacors.Config{
+				AllowOrigins: []string{"ftp://example.com"},
+				AllowMethods: []string{http.MethodGet},
+			},
+		},
+		goacors.RoutePolicy{
+			Actions: []string{"show"},
+		},
+	)
+	if m != nil {
+		t.Error("want nil, got middleware")
+	}
+	var errs goacors.ConfigErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("want ConfigErrors, got %v", err)
+	}
+	fields := []string{
+		"Routes[0].Paths[0]",
+		"Routes[0].Config.AllowOrigins[0]",
+		"Routes[1].Paths",
+		"Routes[1].Actions",
+		"Routes[1].Config",
+	}
+	if len(errs) != len(fields) {
+		t.Fatalf("want %d errors, got %d: %v", len(fields), len(errs), err)
+	}
+	for i, field := range fields {
+		if errs[i].Field != field {
+			t.Errorf("%d: want field %s, got %s", i, field, errs[i].Field)
+		}
+	}
+}
+
+func TestNewSelectorWithErrorNilConfig(t *testing.T) {
+	service := newService(nil)
+	m, err := goacors.NewSelectorWithError(service, nil)
+	if m != nil {
+		t.Error("want nil, got middleware")
+	}
+	var errs goacors.ConfigErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("want ConfigErrors, got %v", err)
+	}
+	if len(errs) != 1 || errs[0].Field != "Config" {
+		t.Errorf("want an error of Config, got %v", err)
+	}
+}
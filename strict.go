@@ -0,0 +1,44 @@
+package goacors
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/shogo82148/goa-v1"
+)
+
+// reject responds 403 Forbidden to the cross-origin request from a disallowed origin in the strict mode.
+// The response body is a goa.ErrorResponse.
+func (c *cors) reject(ctx context.Context, rw http.ResponseWriter) error {
+	err := c.strictError(c.strictMessage)
+	rw.Header().Set(HeaderContentType, goa.ErrorMediaIdentifier)
+	if c.service != nil && goa.ContextResponse(ctx) != nil {
+		return c.service.Send(ctx, http.StatusForbidden, err)
+	}
+
+	// the request is not handled by goa, e.g. the net/http middleware.
+	rw.WriteHeader(http.StatusForbidden)
+	return json.NewEncoder(rw).Encode(err)
+}
+
+// sameOrigin reports whether the origin is the origin of the request itself.
+// The origin of the request is derived from req.Host, and the scheme is "https" if req.TLS is set.
+func (c *cors) sameOrigin(req *http.Request, origin string) bool {
+	if origin == "" || req.Host == "" {
+		return false
+	}
+	o, err := ParseOrigin(origin, c.schemes...)
+	if err != nil {
+		return false
+	}
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	self, err := ParseOrigin(scheme+"://"+req.Host, c.schemes...)
+	if err != nil {
+		return false
+	}
+	return o.Equal(self)
+}
@@ -0,0 +1,169 @@
+package goacors_test
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shogo82148/goa-v1"
+	"github.com/shogo82148/goacors-v1"
+)
+
+func TestStrict(t *testing.T) {
+	testcases := []struct {
+		name    string
+		host    string
+		tls     bool
+		origin  string
+		id      string
+		message string
+		status  int
+		called  bool
+		code    string
+		detail  string
+	}{
+		{
+			name:   "allowed origin",
+			origin: "http://example.com",
+			status: http.StatusOK,
+			called: true,
+		},
+		{
+			name:   "same origin",
+			origin: "",
+			status: http.StatusOK,
+			called: true,
+		},
+		{
+			name:   "same origin with the origin header",
+			host:   "api.example.com",
+			origin: "http://api.example.com",
+			status: http.StatusOK,
+			called: true,
+		},
+		{
+			name:   "same origin with the default port",
+			host:   "api.example.com:443",
+			tls:    true,
+			origin: "https://api.example.com",
+			status: http.StatusOK,
+			called: true,
+		},
+		{
+			name:   "same host with another scheme",
+			host:   "api.example.com",
+			origin: "https://api.example.com",
+			status: http.StatusForbidden,
+			code:   "cors_origin_not_allowed",
+			detail: "origin is not allowed",
+		},
+		{
+			name:   "disallowed origin",
+			origin: "http://evil.com",
+			status: http.StatusForbidden,
+			code:   "cors_origin_not_allowed",
+			detail: "origin is not allowed",
+		},
+		{
+			name:    "custom error",
+			origin:  "http://evil.com",
+			id:      "forbidden_origin",
+			message: "cross-origin requests are not allowed",
+			status:  http.StatusForbidden,
+			code:    "forbidden_origin",
+			detail:  "cross-origin requests are not allowed",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			service := newService(nil)
+			req, _ := http.NewRequest(http.MethodPost, "/", nil)
+			req.Host = tc.host
+			if tc.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			if tc.origin != "" {
+				req.Header.Set(goacors.HeaderOrigin, tc.origin)
+			}
+			rw := newTestResponseWriter()
+			ctx := newContext(service, rw, req, nil)
+
+			called := false
+			h := func(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+				called = true
+				return service.Send(ctx, http.StatusOK, "ok")
+			}
+			testee := goacors.New(service, &goacors.Config{
+				AllowOrigins:       []string{"http://example.com"},
+				AllowMethods:       []string{http.MethodPost},
+				Strict:             true,
+				StrictErrorID:      tc.id,
+				StrictErrorMessage: tc.message,
+			})(h)
+			if err := testee(ctx, rw, req); err != nil {
+				t.Fatal("it should not return any error but ", err)
+			}
+
+			if called != tc.called {
+				t.Errorf("handler called should be %v but %v", tc.called, called)
+			}
+			if rw.Status != tc.status {
+				t.Errorf("status should be %d but %d", tc.status, rw.Status)
+			}
+			if tc.code == "" {
+				return
+			}
+
+			if got := rw.Header().Get(goacors.HeaderContentType); got != goa.ErrorMediaIdentifier {
+				t.Errorf("content type should be %q but %q", goa.ErrorMediaIdentifier, got)
+			}
+			var body goa.ErrorResponse
+			if err := json.Unmarshal(rw.Body, &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Code != tc.code {
+				t.Errorf("code should be %q but %q", tc.code, body.Code)
+			}
+			if body.Detail != tc.detail {
+				t.Errorf("detail should be %q but %q", tc.detail, body.Detail)
+			}
+			if body.Status != http.StatusForbidden {
+				t.Errorf("status in the body should be %d but %d", http.StatusForbidden, body.Status)
+			}
+		})
+	}
+}
+
+func TestStrictHandler(t *testing.T) {
+	called := false
+	h := goacors.NewHandler(&goacors.Config{
+		AllowOrigins: []string{"http://example.com"},
+		AllowMethods: []string{http.MethodPost},
+		Strict:       true,
+	})(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		called = true
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set(goacors.HeaderOrigin, "http://evil.com")
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, req)
+
+	if called {
+		t.Error("handler should not be called")
+	}
+	if rw.Code != http.StatusForbidden {
+		t.Errorf("status should be %d but %d", http.StatusForbidden, rw.Code)
+	}
+	var body goa.ErrorResponse
+	if err := json.Unmarshal(rw.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Code != "cors_origin_not_allowed" {
+		t.Errorf("code should be %q but %q", "cors_origin_not_allowed", body.Code)
+	}
+}
@@ -0,0 +1,221 @@
+package goacors
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ConfigError describes a problem in a field of Config.
+type ConfigError struct {
+	// Field is the name of the field, e.g. "AllowOrigins[0]".
+	Field string
+
+	// Value is the invalid value.
+	Value string
+
+	// Err is the reason why the value is invalid.
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	// ConfigErrors has the prefix, so the prefix of the reason is redundant.
+	msg := strings.TrimPrefix(e.Err.Error(), "goacors: ")
+	if e.Value == "" {
+		return e.Field + ": " + msg
+	}
+	return fmt.Sprintf("%s: %q: %s", e.Field, e.Value, msg)
+}
+
+// Unwrap returns the reason why the value is invalid.
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// ConfigErrors is a list of problems found by Config.Validate.
+type ConfigErrors []*ConfigError
+
+func (e ConfigErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "goacors: invalid config: " + strings.Join(msgs, "; ")
+}
+
+var errUnsafeCredentials = errors.New("credentials are not allowed with wildcard origin. set UnsafeAllowAnyOriginWithCredentials to allow it")
+
+// unsafeCredentials returns the fields that allow any origin to make credentialed requests.
+func (conf *Config) unsafeCredentials() []string {
+	var fields []string
+	if conf.AllowCredentials {
+		if containsString(conf.AllowOrigins, "*") {
+			fields = append(fields, "AllowCredentials")
+		}
+		if matchesEveryOrigin(conf.AllowOriginMatcher) {
+			fields = append(fields, "AllowOriginMatcher")
+		}
+	}
+	for i, op := range conf.OriginPolicies {
+		if op.AllowCredentials && matchesEveryOrigin(op.Matcher) {
+			fields = append(fields, fmt.Sprintf("OriginPolicies[%d].Matcher", i))
+		}
+	}
+	return fields
+}
+
+// Validate validates the configure.
+// It reports all problems at once as ConfigErrors.
+func (conf *Config) Validate() error {
+	v := &validator{schemes: conf.AllowSchemes}
+
+	for i, scheme := range conf.AllowSchemes {
+		if err := validateScheme(scheme); err != nil {
+			v.add(fmt.Sprintf("AllowSchemes[%d]", i), scheme, err)
+		}
+	}
+
+	for i, origin := range conf.AllowOrigins {
+		field := fmt.Sprintf("AllowOrigins[%d]", i)
+		if origin == "*" {
+			if len(conf.AllowOrigins) > 1 {
+				v.add(field, origin, errors.New("wildcard must be the only entry"))
+			}
+			continue
+		}
+		v.allowedOrigin(field, origin, conf.AllowPortWildcardsOnAnyHost)
+	}
+	if !conf.UnsafeAllowAnyOriginWithCredentials {
+		for _, field := range conf.unsafeCredentials() {
+			v.add(field, "", errUnsafeCredentials)
+		}
+	}
+	v.patterns("AllowOriginPatterns", conf.AllowOriginPatterns)
+
+	for i, origin := range conf.DenyOrigins {
+		field := fmt.Sprintf("DenyOrigins[%d]", i)
+		if origin == "*" {
+			v.add(field, origin, errors.New("wildcard is not allowed in denied origins"))
+			continue
+		}
+		v.origin(field, origin)
+	}
+
+	if len(conf.AllowMethods) == 0 && !conf.AllowMethodsFromMux {
+		v.add("AllowMethods", "", errors.New("empty method list"))
+	}
+	v.policy("", conf.defaultPolicy())
+
+	for i, op := range conf.OriginPolicies {
+		prefix := fmt.Sprintf("OriginPolicies[%d].", i)
+		if len(op.Origins) == 0 && len(op.OriginPatterns) == 0 && op.Matcher == nil {
+			v.add(prefix+"Origins", "", errors.New("empty origin list"))
+		}
+		for j, origin := range op.Origins {
+			field := fmt.Sprintf("%sOrigins[%d]", prefix, j)
+			if origin == "*" {
+				v.add(field, origin, errors.New("wildcard is not allowed in origin policies"))
+				continue
+			}
+			v.allowedOrigin(field, origin, conf.AllowPortWildcardsOnAnyHost)
+		}
+		v.patterns(prefix+"OriginPatterns", op.OriginPatterns)
+		v.policy(prefix, op.Policy)
+	}
+
+	if status := conf.PreflightRejectStatus; status != 0 && (status < 200 || status > 599) {
+		v.add("PreflightRejectStatus", fmt.Sprint(status), errors.New("invalid status code"))
+	}
+
+	if conf.ReportOnly != nil {
+		if err := conf.ReportOnly.Validate(); err != nil {
+			for _, e := range err.(ConfigErrors) {
+				v.add("ReportOnly."+e.Field, e.Value, e.Err)
+			}
+		}
+	}
+
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+	return nil
+}
+
+// validator collects the problems in a Config.
+type validator struct {
+	schemes []string
+	errs    ConfigErrors
+}
+
+func (v *validator) add(field, value string, err error) {
+	v.errs = append(v.errs, &ConfigError{
+		Field: field,
+		Value: value,
+		Err:   err,
+	})
+}
+
+func (v *validator) origin(field, origin string) {
+	if _, err := ParsePattern(origin, v.schemes...); err != nil {
+		v.add(field, origin, err)
+	}
+}
+
+// allowedOrigin validates an allowed origin.
+// Unlike denied origins, port wildcards are restricted to loopback hosts unless anyHost is true.
+func (v *validator) allowedOrigin(field, origin string, anyHost bool) {
+	p, err := ParsePattern(origin, v.schemes...)
+	if err != nil {
+		v.add(field, origin, err)
+		return
+	}
+	if !anyHost && p.hasPortRange() && !p.isLoopback() {
+		v.add(field, origin, errors.New("port wildcards are allowed only on loopback hosts. set AllowPortWildcardsOnAnyHost to allow it"))
+	}
+}
+
+func (v *validator) patterns(field string, patterns []string) {
+	for i, pattern := range patterns {
+		if _, err := compileOriginPattern(pattern); err != nil {
+			v.add(fmt.Sprintf("%s[%d]", field, i), pattern, err)
+		}
+	}
+}
+
+func (v *validator) tokens(field string, tokens []string, msg string) {
+	for i, token := range tokens {
+		if !isToken(token) {
+			v.add(fmt.Sprintf("%s[%d]", field, i), token, errors.New(msg))
+		}
+	}
+}
+
+func (v *validator) policy(prefix string, p Policy) {
+	v.tokens(prefix+"AllowMethods", p.AllowMethods, "invalid method")
+	v.tokens(prefix+"AllowHeaders", p.AllowHeaders, "invalid header name")
+	v.tokens(prefix+"ExposeHeaders", p.ExposeHeaders, "invalid header name")
+	if p.MaxAge < 0 {
+		v.add(prefix+"MaxAge", fmt.Sprint(p.MaxAge), errors.New("negative max age"))
+	}
+}
+
+// validateScheme validates an entry of AllowSchemes.
+// https://www.rfc-editor.org/rfc/rfc3986#section-3.1
+// > scheme = ALPHA *( ALPHA / DIGIT / "+" / "-" / "." )
+func validateScheme(scheme string) error {
+	if strings.EqualFold(scheme, "http") || strings.EqualFold(scheme, "https") {
+		return errors.New("http and https are always allowed")
+	}
+	if scheme == "" {
+		return errors.New("empty scheme")
+	}
+	for i, c := range scheme {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case i > 0 && ('0' <= c && c <= '9' || c == '+' || c == '-' || c == '.'):
+		default:
+			return errors.New("invalid scheme")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,309 @@
+package goacors_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/shogo82148/goacors-v1"
+)
+
+func TestValidate(t *testing.T) {
+	testcases := []struct {
+		name   string
+		conf   *goacors.Config
+		fields []string
+	}{
+		{
+			name: "valid",
+			conf: &goacors.Config{
+				AllowOrigins:        []string{"http://example.com", "https://*.example.com:8443"},
+				AllowOriginPatterns: []string{`https://pr-[0-9]+\.example\.com`},
+				AllowMethods:        []string{http.MethodGet, http.MethodPut},
+				AllowHeaders:        []string{"X-Foo"},
+				ExposeHeaders:       []string{"ETag"},
+				AllowCredentials:    true,
+				MaxAge:              3600,
+			},
+		},
+		{
+			name: "wildcard",
+			conf: &goacors.Config{
+				AllowOrigins: []string{"*"},
+				AllowMethods: []string{http.MethodGet},
+			},
+		},
+		{
+			name: "bad scheme",
+			conf: &goacors.Config{
+				AllowOrigins: []string{"http://example.com", "ftp://example.com", "example.com"},
+				AllowMethods: []string{http.MethodGet},
+			},
+			fields: []string{"AllowOrigins[1]", "AllowOrigins[2]"},
+		},
+		{
+			name: "bad port",
+			conf: &goacors.Config{
+				AllowOrigins: []string{"http://example.com:http", "http://example.com:65536"},
+				AllowMethods: []string{http.MethodGet},
+			},
+			fields: []string{"AllowOrigins[0]", "AllowOrigins[1]"},
+		},
+		{
+			name: "misplaced wildcard",
+			conf: &goacors.Config{
+				AllowOrigins: []string{"http://foo.*.example.com", "http://*example.com", "http://*", "http://example.com", "*"},
+				AllowMethods: []string{http.MethodGet},
+			},
+			fields: []string{"AllowOrigins[0]", "AllowOrigins[1]", "AllowOrigins[2]", "AllowOrigins[4]"},
+		},
+		{
+			name: "origin with path",
+			conf: &goacors.Config{
+				AllowOrigins: []string{"http://example.com/", "http://example.com/foo", "http://user@example.com"},
+				AllowMethods: []string{http.MethodGet},
+			},
+			fields: []string{"AllowOrigins[1]", "AllowOrigins[2]"},
+		},
+		{
+			name: "credentials with wildcard",
+			conf: &goacors.Config{
+				AllowOrigins:     []string{"*"},
+				AllowMethods:     []string{http.MethodGet},
+				AllowCredentials: true,
+			},
+			fields: []string{"AllowCredentials"},
+		},
+		{
+			name: "unsafe credentials with wildcard",
+			conf: &goacors.Config{
+				AllowOrigins:                        []string{"*"},
+				AllowMethods:                        []string{http.MethodGet},
+				AllowCredentials:                    true,
+				UnsafeAllowAnyOriginWithCredentials: true,
+			},
+		},
+		{
+			name: "credentials with matchers of any origin",
+			conf: &goacors.Config{
+				AllowOriginMatcher: goacors.AnyOf(goacors.MatchExact(goacors.Origin{Scheme: "https", Host: "example.com", Port: 443}), goacors.MatchAny()),
+				AllowMethods:       []string{http.MethodGet},
+				AllowCredentials:   true,
+				OriginPolicies: []goacors.OriginPolicy{
+					{
+						Matcher: goacors.Not(goacors.Not(goacors.MatchAny())),
+						Policy: goacors.Policy{
+							AllowMethods:     []string{http.MethodGet},
+							AllowCredentials: true,
+						},
+					},
+					{
+						Matcher: goacors.MatchAny(),
+						Policy: goacors.Policy{
+							AllowMethods: []string{http.MethodGet},
+						},
+					},
+				},
+			},
+			fields: []string{"AllowOriginMatcher", "OriginPolicies[0].Matcher"},
+		},
+		{
+			name: "bad pattern",
+			conf: &goacors.Config{
+				AllowOriginPatterns: []string{`https://(.example\.com`},
+				AllowMethods:        []string{http.MethodGet},
+			},
+			fields: []string{"AllowOriginPatterns[0]"},
+		},
+		{
+			name: "empty method list",
+			conf: &goacors.Config{
+				AllowOrigins: []string{"http://example.com"},
+			},
+			fields: []string{"AllowMethods"},
+		},
+		{
+			name: "bad tokens",
+			conf: &goacors.Config{
+				AllowOrigins:  []string{"http://example.com"},
+				AllowMethods:  []string{"GET PUT"},
+				AllowHeaders:  []string{"X-Foo, X-Bar"},
+				ExposeHeaders: []string{""},
+			},
+			fields: []string{"AllowMethods[0]", "AllowHeaders[0]", "ExposeHeaders[0]"},
+		},
+		{
+			name: "bad numbers",
+			conf: &goacors.Config{
+				AllowOrigins:          []string{"http://example.com"},
+				AllowMethods:          []string{http.MethodGet},
+				MaxAge:                -1,
+				PreflightRejectStatus: 42,
+			},
+			fields: []string{"MaxAge", "PreflightRejectStatus"},
+		},
+		{
+			name: "informational reject status",
+			conf: &goacors.Config{
+				AllowOrigins:          []string{"http://example.com"},
+				AllowMethods:          []string{http.MethodGet},
+				PreflightRejectStatus: http.StatusContinue,
+			},
+			fields: []string{"PreflightRejectStatus"},
+		},
+		{
+			name: "unknown reject status",
+			conf: &goacors.Config{
+				AllowOrigins:          []string{"http://example.com"},
+				AllowMethods:          []string{http.MethodGet},
+				PreflightRejectStatus: 999,
+			},
+			fields: []string{"PreflightRejectStatus"},
+		},
+		{
+			name: "port wildcards",
+			conf: &goacors.Config{
+				AllowOrigins: []string{
+					"http://localhost:*",
+					"http://127.0.0.1:3000-3999",
+					"http://[::1]:*",
+					"http://example.com:*",
+					"http://localhost:3999-3000",
+					"http://localhost:0-80",
+				},
+				AllowMethods: []string{http.MethodGet},
+			},
+			fields: []string{"AllowOrigins[3]", "AllowOrigins[4]", "AllowOrigins[5]"},
+		},
+		{
+			name: "port wildcards on any host",
+			conf: &goacors.Config{
+				AllowOrigins:                []string{"http://localhost:*", "https://*.example.com:8000-8999"},
+				AllowMethods:                []string{http.MethodGet},
+				AllowPortWildcardsOnAnyHost: true,
+			},
+		},
+		{
+			name: "extra schemes",
+			conf: &goacors.Config{
+				AllowSchemes: []string{"capacitor", "chrome-extension", "https", "1abc", ""},
+				AllowOrigins: []string{"capacitor://localhost", "chrome-extension://abcdefghijklmnop", "ionic://localhost", "capacitor://localhost:*"},
+				AllowMethods: []string{http.MethodGet},
+			},
+			fields: []string{"AllowSchemes[2]", "AllowSchemes[3]", "AllowSchemes[4]", "AllowOrigins[2]", "AllowOrigins[3]"},
+		},
+		{
+			name: "deny origins",
+			conf: &goacors.Config{
+				AllowOrigins: []string{"https://*.example.com"},
+				DenyOrigins:  []string{"https://*.sites.example.com", "*", "ftp://example.com"},
+				AllowMethods: []string{http.MethodGet},
+			},
+			fields: []string{"DenyOrigins[1]", "DenyOrigins[2]"},
+		},
+		{
+			name: "origin policies",
+			conf: &goacors.Config{
+				AllowOrigins: []string{"http://example.com"},
+				AllowMethods: []string{http.MethodGet},
+				OriginPolicies: []goacors.OriginPolicy{
+					{
+						Origins:        []string{"https://*.example.com", "*"},
+						OriginPatterns: []string{`https://(.example\.com`},
+						Policy: goacors.Policy{
+							AllowMethods: []string{"GET PUT"},
+							MaxAge:       -1,
+						},
+					},
+					{
+						Policy: goacors.Policy{
+							AllowMethods: []string{http.MethodGet},
+						},
+					},
+				},
+			},
+			fields: []string{
+				"OriginPolicies[0].Origins[1]",
+				"OriginPolicies[0].OriginPatterns[0]",
+				"OriginPolicies[0].AllowMethods[0]",
+				"OriginPolicies[0].MaxAge",
+				"OriginPolicies[1].Origins",
+			},
+		},
+		{
+			name: "report-only",
+			conf: &goacors.Config{
+				AllowOrigins: []string{"http://example.com"},
+				AllowMethods: []string{http.MethodGet},
+				ReportOnly: &goacors.Config{
+					AllowOrigins: []string{"ftp://example.com"},
+					AllowMethods: []string{http.MethodGet},
+				},
+			},
+			fields: []string{"ReportOnly.AllowOrigins[0]"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.conf.Validate()
+			if len(tc.fields) == 0 {
+				if err != nil {
+					t.Errorf("want no error, got %v", err)
+				}
+				return
+			}
+
+			var errs goacors.ConfigErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("want ConfigErrors, got %v", err)
+			}
+			if len(errs) != len(tc.fields) {
+				t.Fatalf("want %d errors, got %d: %v", len(tc.fields), len(errs), err)
+			}
+			for i, field := range tc.fields {
+				if errs[i].Field != field {
+					t.Errorf("%d: want field %s, got %s", i, field, errs[i].Field)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigErrorsMessage(t *testing.T) {
+	err := (&goacors.Config{
+		AllowOrigins: []string{"http://*", "ftp://example.com"},
+		AllowMethods: []string{http.MethodGet},
+	}).Validate()
+	want := `goacors: invalid config: AllowOrigins[0]: "http://*": wildcard must be followed by a domain: *; ` +
+		`AllowOrigins[1]: "ftp://example.com": unknown scheme: ftp`
+	if err == nil || err.Error() != want {
+		t.Errorf("want %q, got %v", want, err)
+	}
+}
+
+func TestNewWithError(t *testing.T) {
+	service := newService(nil)
+
+	m, err := goacors.NewWithError(service, &goacors.Config{
+		AllowOrigins: []string{"http://example.com"},
+		AllowMethods: []string{http.MethodGet},
+	})
+	if err != nil {
+		t.Errorf("want no error, got %v", err)
+	}
+	if m == nil {
+		t.Error("want middleware, got nil")
+	}
+
+	m, err = goacors.NewWithError(service, &goacors.Config{
+		AllowOrigins: []string{"ftp://example.com"},
+		AllowMethods: []string{http.MethodGet},
+	})
+	if err == nil {
+		t.Error("want error, got nil")
+	}
+	if m != nil {
+		t.Error("want nil, got middleware")
+	}
+}
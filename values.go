@@ -26,6 +26,10 @@ const (
 	HeaderAccessControlExposeHeaders = "Access-Control-Expose-Headers"
 	// HeaderAccessControlMaxAge "Access-Control-Max-Age"
 	HeaderAccessControlMaxAge = "Access-Control-Max-Age"
+	// HeaderAccessControlRequestPrivateNetwork "Access-Control-Request-Private-Network"
+	HeaderAccessControlRequestPrivateNetwork = "Access-Control-Request-Private-Network"
+	// HeaderAccessControlAllowPrivateNetwork "Access-Control-Allow-Private-Network"
+	HeaderAccessControlAllowPrivateNetwork = "Access-Control-Allow-Private-Network"
 	// HeaderContentType "Content-Type"
 	HeaderContentType = "Content-Type"
 )
@@ -34,24 +38,99 @@ const (
 // the middleware.
 type Skipper func(c context.Context, rw http.ResponseWriter, req *http.Request) bool
 
+// AllowOriginFunc defines a function to decide whether the origin may access the resource.
+// Returning a non-nil error aborts the request, and the error is returned from the middleware.
+type AllowOriginFunc func(ctx context.Context, origin string, req *http.Request) (bool, error)
+
 // Config is a config for the CORS middleware.
 type Config struct {
 	// Skipper defines a function to skip middleware.
 	Skipper Skipper
 
 	// AllowOrigin defines a list of origins that may access the resource.
+	// The entries may contain wildcard domains, e.g. "https://*.example.com",
+	// and port wildcards or port ranges, e.g. "http://localhost:*" or "http://127.0.0.1:3000-3999".
 	// Default value is an empty list, any origin can not access.
 	AllowOrigins []string
 
+	// AllowSchemes defines a list of the origin schemes accepted in addition to http and https,
+	// e.g. "capacitor" and "ionic" for hybrid apps, or "chrome-extension" and "moz-extension" for browser extensions.
+	// The schemes have no default port, and the origins match AllowOrigins by the scheme and the host,
+	// e.g. "capacitor://localhost" or "chrome-extension://<extension id>".
+	// Port wildcards and port ranges are not allowed for the schemes.
+	// Default value is an empty list, only http and https are accepted.
+	AllowSchemes []string
+
+	// AllowPortWildcardsOnAnyHost allows port wildcards and port ranges on the hosts other than loopback hosts.
+	// Without it, they are accepted only on localhost and loopback addresses, and New panics for the other hosts.
+	// Default value is false.
+	AllowPortWildcardsOnAnyHost bool
+
+	// DenyOrigins defines a list of origins that may not access the resource.
+	// The syntax is the same as AllowOrigins including wildcard domains, but "*" is not allowed.
+	// It is evaluated before all allow rules, so it can carve out untrusted subdomains from wildcard domains.
+	// Default value is an empty list.
+	DenyOrigins []string
+
+	// AllowOriginPatterns defines a list of regular expressions of origins that
+	// may access the resource. The patterns are anchored, they must match the whole origin.
+	// e.g. `https://pr-[0-9]+\.preview\.example\.net`
+	// Default value is an empty list.
+	AllowOriginPatterns []string
+
+	// AllowOriginMatcher is an OriginMatcher that decides the origins that may access the resource,
+	// in addition to AllowOrigins and AllowOriginPatterns.
+	// The matched origin is reflected in Access-Control-Allow-Origin.
+	// e.g. AllOf(pattern, Not(MatchExact(evil))) allows the subdomains except evil.
+	// Default value is nil.
+	AllowOriginMatcher OriginMatcher
+
+	// OriginPolicies defines the policies applied to specific origins instead of the default policy,
+	// which consists of AllowMethods, AllowHeaders, AllowCredentials, ExposeHeaders and MaxAge.
+	// The origins listed in OriginPolicies are allowed in addition to AllowOrigins and AllowOriginPatterns.
+	// If several entries match an origin, the most specific one wins: exact origins, wildcard domains
+	// with more labels, wildcard domains with fewer labels, and then regular expressions.
+	// If they are equally specific, OriginPolicies win over AllowOrigins and AllowOriginPatterns.
+	// Default value is an empty list.
+	OriginPolicies []OriginPolicy
+
+	// AllowOriginFunc is called when the origin matches none of AllowOrigins, AllowOriginPatterns,
+	// AllowOriginMatcher and OriginPolicies.
+	// It is useful for the origins that can change at runtime.
+	// Default value is nil.
+	AllowOriginFunc AllowOriginFunc
+
 	// AllowMethods defines a list methods allowed when accessing the resource.
 	// This is used in response to a preflight request.
+	// "*" allows any method. It is sent as is to non-credentialed requests, and the requested method
+	// is reflected for credentialed requests, because browsers treat "*" as a literal method name for them.
 	// Default value is an empty list, any method is not allowed.
 	AllowMethods []string
 
+	// AllowMethodsFromMux derives the methods allowed by preflight requests from the routes
+	// registered on service.Mux for the requested path. If AllowMethods is not empty,
+	// the derived methods are restricted to AllowMethods.
+	// The preflight requests for the path that has no route are rejected with ReasonMethodNotAllowed.
+	// New replaces service.Mux with a RouteRecorder, so New must be called before
+	// the controllers are mounted. It is ignored by NewHandler.
+	// Default value is false.
+	AllowMethodsFromMux bool
+
 	// AllowHeaders defines a list of request headers that can be used when
 	// making the actual request. This in response to a preflight request.
+	// Header names are case insensitive.
+	// "*" allows any header except Authorization, which must be listed explicitly.
+	// It is sent as is to non-credentialed requests, and the requested headers
+	// are reflected for credentialed requests.
+	// Default value is an empty list, any non-safelisted header is not allowed.
 	AllowHeaders []string
 
+	// ReflectRequestHeaders allows any request headers, and reflects the headers
+	// listed in Access-Control-Request-Headers back to Access-Control-Allow-Headers.
+	// AllowHeaders is ignored if it is true.
+	// Default value is false.
+	ReflectRequestHeaders bool
+
 	// AllowCredentials indicates whether or not the response to the request
 	// can be exposed when the credentials flag is true. When used as part of
 	// a response to a preflight request, this indicates whether or not the
@@ -59,13 +138,102 @@ type Config struct {
 	// Default value is false.
 	AllowCredentials bool
 
+	// UnsafeAllowAnyOriginWithCredentials allows the combination of "*" in AllowOrigins and AllowCredentials.
+	// It also allows the combination of AllowCredentials and the matchers that match any origin,
+	// e.g. AnyOf(MatchAny()) in AllowOriginMatcher or OriginPolicy.Matcher.
+	// With the combination, the middleware reflects any origin with Access-Control-Allow-Credentials,
+	// which effectively disables the same-origin policy for the cookie-authenticated APIs.
+	// It is refused unless this flag is set, and a warning is logged by the service logger when it is set.
+	// Default value is false.
+	UnsafeAllowAnyOriginWithCredentials bool
+
 	// ExposeHeaders defines a whitelist headers that clients are allowed to
 	// access.
+	// "*" exposes all headers for non-credentialed requests. It is omitted if AllowCredentials is true,
+	// because browsers treat it as a literal header name, so list the headers explicitly.
 	// Default value is an empty list.
 	ExposeHeaders []string
 
+	// AllowPrivateNetwork indicates whether or not the preflight requests of Private Network Access
+	// are allowed. If it is true, the response to a preflight request with
+	// "Access-Control-Request-Private-Network: true" contains "Access-Control-Allow-Private-Network: true".
+	// https://wicg.github.io/private-network-access/
+	// Default value is false.
+	AllowPrivateNetwork bool
+
 	// MaxAge indicates how long (in seconds) the results of a preflight request
 	// can be cached.
 	// The default value is 0, the preflight request can not be cached.
 	MaxAge int
+
+	// PreflightRejectStatus is the status code used to respond to a preflight request
+	// whose origin, method or headers are not allowed, e.g. http.StatusForbidden.
+	// The Access-Control-Allow-* headers are not sent in the response.
+	// It must be between 200 and 599, and New panics for the other values.
+	// Default value is 0, http.StatusNoContent is used.
+	PreflightRejectStatus int
+
+	// OnReject is called when the request is rejected. The reason is available as Decision.Reason.
+	// It is called for both preflight requests and actual requests, and must not write the response.
+	// It is not called for the same-origin requests with the Origin header, see Strict.
+	// Default value is nil.
+	OnReject RejectFunc
+
+	// OptionsPassthrough passes the preflight requests to the next handler after the CORS headers are set,
+	// instead of responding to them by the middleware.
+	// The OPTIONS requests that are not preflight requests always reach the next handler.
+	// Default value is false.
+	OptionsPassthrough bool
+
+	// Strict enables the strict mode.
+	// In the strict mode, the cross-origin requests from disallowed origins are rejected
+	// with 403 Forbidden, and the next handler is not called.
+	// Without it, only the Access-Control-Allow-Origin header is omitted,
+	// so the "simple" requests that are not preflighted still reach the handler.
+	// Browsers also send the Origin header in same-origin POST requests, so the requests
+	// whose origin equals the scheme and Host of the request itself are not rejected.
+	// The scheme is "https" only if the request is received over TLS; list the origin of
+	// the service in AllowOrigins if it is served behind a TLS-terminating proxy.
+	// Default value is false.
+	Strict bool
+
+	// StrictErrorID identifies the error returned in the strict mode.
+	// It is used as the code of goa.ErrorResponse.
+	// Default value is "", "cors_origin_not_allowed" is used.
+	StrictErrorID string
+
+	// StrictErrorMessage is the detail of the error returned in the strict mode.
+	// Default value is "", "origin is not allowed" is used.
+	StrictErrorMessage string
+
+	// LogLevel is the verbosity of the logs of CORS decisions.
+	// The logs are written by the logger attached to the request context.
+	// Default value is LogLevelNone, no logs are written.
+	LogLevel LogLevel
+
+	// EnableMetrics enables the metrics of CORS decisions.
+	// The counters are emitted through goa.IncrCounter with the keys
+	// "goacors.request.{allowed,rejected}.<origin>", "goacors.preflight.{allowed,rejected}.<origin>",
+	// "goacors.rejected.<reason>.<origin>" and "goacors.skipped".
+	// The characters of the origins other than alphanumerics are replaced with "_".
+	// Default value is false.
+	EnableMetrics bool
+
+	// MetricsMaxOrigins is the maximum number of distinct origins used in the keys of the metrics.
+	// The other origins are counted as "other".
+	// Default value is 0, 100 origins are used.
+	MetricsMaxOrigins int
+
+	// ReportOnly is a candidate configure evaluated in report-only mode.
+	// The middleware evaluates requests with both configures, and reports the divergence
+	// by OnDivergence, but the response is always built from the enforced configure.
+	// Skipper, LogLevel, metrics and report-only settings of ReportOnly are ignored.
+	// Default value is nil, report-only mode is disabled.
+	ReportOnly *Config
+
+	// OnDivergence is called when the decision of ReportOnly differs from the enforced one
+	// in Reason, AllowedOrigin or Credentialed.
+	// Default value is nil, the divergence is logged by the logger attached to the request context
+	// if LogLevel is LogLevelRejected or higher.
+	OnDivergence DivergenceFunc
 }